@@ -35,3 +35,32 @@ type Cache interface {
 	// Decrement decrement numeric item by int, return false if item not exists
 	Decrement(key string, value int64) (bool, error)
 }
+
+// cacheWrapper implemented by cache wrappers (metrics, ...) to expose wrapped cache
+// and key mapper (nil for unchanged keys), unwrap return nil cache if wrapper change
+// operations and wrapped cache must not used directly
+type cacheWrapper interface {
+	unwrap() (Cache, func(key string) string)
+}
+
+// unwrapCache get innermost exposed wrapped cache and key mapper of wrappers
+func unwrapCache(c Cache) (Cache, func(key string) string) {
+	mapper := func(key string) string { return key }
+	for {
+		w, ok := c.(cacheWrapper)
+		if !ok {
+			return c, mapper
+		}
+
+		inner, keys := w.unwrap()
+		if inner == nil {
+			return c, mapper
+		}
+
+		if keys != nil {
+			outer := mapper
+			mapper = func(key string) string { return keys(outer(key)) }
+		}
+		c = inner
+	}
+}
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomig/caster"
+	"github.com/gomig/utils"
+)
+
+// CacheCall cache operation descriptor passed to middlewares. operations are put,
+// put_forever, set, get, exists, forget, pull, ttl, increment, increment_float,
+// decrement and decrement_float. Cast called as get operation. map_key operation
+// sent by Chain for detecting key only middlewares, see keysOf
+type CacheCall struct {
+	// Op operation name
+	Op string
+	// Key operation key
+	Key string
+	// Value put and set value or increment and decrement amount
+	Value any
+	// TTL put ttl
+	TTL time.Duration
+	// Ctx operation context bound by WithContext, nil if not bound
+	Ctx context.Context
+}
+
+// CacheHandler execute cache call. result is nil for put, put_forever and forget,
+// bool for set, exists, increments and decrements, value for get and pull and
+// time.Duration for ttl
+type CacheHandler func(call CacheCall) (any, error)
+
+// CacheMiddleware intercept cache call, call next to continue chain
+type CacheMiddleware func(call CacheCall, next CacheHandler) (any, error)
+
+// keyMapper map_key operation result of middlewares not changing calls except
+// keys, nil mapper for middlewares not changing keys
+type keyMapper func(key string) string
+
+// KeyMapper create middleware changing keys only. chains of key only middlewares
+// expose wrapped cache and drivers (rate limiters, locks, ...) keep their native
+// redis and file implementation with mapped keys
+func KeyMapper(mapper func(key string) string) CacheMiddleware {
+	return func(call CacheCall, next CacheHandler) (any, error) {
+		if call.Op == "map_key" {
+			return keyMapper(mapper), nil
+		}
+		call.Key = mapper(call.Key)
+		return next(call)
+	}
+}
+
+// KeyPrefix create key only middleware prefixing all keys with prefix
+func KeyPrefix(prefix string) CacheMiddleware {
+	return KeyMapper(func(key string) string {
+		return utils.ConcatStr("-", prefix, key)
+	})
+}
+
+// keysOf get key mapper of middlewares, return false if any middleware change
+// calls except keys. map_key call next return nil mapper, so pass through
+// middlewares keep chain key only and value changing middlewares must return
+// nil for map_key
+func keysOf(middlewares ...CacheMiddleware) (func(key string) string, bool) {
+	mappers := make([]keyMapper, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		v, _ := middleware(CacheCall{Op: "map_key"}, func(CacheCall) (any, error) {
+			return keyMapper(nil), nil
+		})
+
+		if mapper, ok := v.(keyMapper); !ok {
+			return nil, false
+		} else if mapper != nil {
+			mappers = append(mappers, mapper)
+		}
+	}
+
+	return func(key string) string {
+		for _, mapper := range mappers {
+			key = mapper(key)
+		}
+		return key
+	}, true
+}
+
+// callResult get cache call result (ok, error, hit, miss)
+func callResult(call CacheCall, v any, err error) string {
+	switch call.Op {
+	case "get", "pull":
+		return metricResult(err, v != nil)
+	case "set", "exists", "increment", "increment_float", "decrement", "decrement_float":
+		found, _ := v.(bool)
+		return metricResult(err, found)
+	default:
+		return metricResult(err)
+	}
+}
+
+// cCache cache calling middlewares chain around operations. transparent chains
+// and chains of key only middlewares expose wrapped cache and key mapper to
+// native drivers
+type cCache struct {
+	cache   Cache
+	handler CacheHandler
+	keys    func(key string) string
+	exposed bool
+	ctx     context.Context
+}
+
+func (cc *cCache) init(cache Cache, transparent bool, middlewares ...CacheMiddleware) {
+	cc.cache = cache
+	if transparent {
+		cc.exposed = true
+	} else {
+		cc.keys, cc.exposed = keysOf(middlewares...)
+	}
+
+	cc.handler = cc.execute
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		middleware, next := middlewares[i], cc.handler
+		cc.handler = func(call CacheCall) (any, error) {
+			return middleware(call, next)
+		}
+	}
+}
+
+func (cc cCache) unwrap() (Cache, func(key string) string) {
+	if cc.exposed {
+		return cc.cache, cc.keys
+	}
+	return nil, nil
+}
+
+// execute call wrapped cache operation, call context bound to wrapped chains
+func (cc cCache) execute(call CacheCall) (any, error) {
+	cache := cc.cache
+	if call.Ctx != nil {
+		cache = WithContext(cache, call.Ctx)
+	}
+
+	switch call.Op {
+	case "put":
+		return nil, cache.Put(call.Key, call.Value, call.TTL)
+	case "put_forever":
+		return nil, cache.PutForever(call.Key, call.Value)
+	case "set":
+		return cache.Set(call.Key, call.Value)
+	case "get":
+		return cache.Get(call.Key)
+	case "exists":
+		return cache.Exists(call.Key)
+	case "forget":
+		return nil, cache.Forget(call.Key)
+	case "pull":
+		return cache.Pull(call.Key)
+	case "ttl":
+		return cache.TTL(call.Key)
+	case "increment":
+		return cache.Increment(call.Key, caster.NewCaster(call.Value).Int64Safe(0))
+	case "increment_float":
+		return cache.IncrementFloat(call.Key, caster.NewCaster(call.Value).Float64Safe(0))
+	case "decrement":
+		return cache.Decrement(call.Key, caster.NewCaster(call.Value).Int64Safe(0))
+	case "decrement_float":
+		return cache.DecrementFloat(call.Key, caster.NewCaster(call.Value).Float64Safe(0))
+	default:
+		return nil, utils.TaggedError([]string{"CacheChain"}, "unknown operation %s", call.Op)
+	}
+}
+
+// flag run call with bool result
+func (cc cCache) flag(call CacheCall) (bool, error) {
+	v, err := cc.handler(call)
+	res, _ := v.(bool)
+	return res, err
+}
+
+func (cc cCache) Put(key string, value any, ttl time.Duration) error {
+	_, err := cc.handler(CacheCall{Op: "put", Key: key, Value: value, TTL: ttl, Ctx: cc.ctx})
+	return err
+}
+
+func (cc cCache) PutForever(key string, value any) error {
+	_, err := cc.handler(CacheCall{Op: "put_forever", Key: key, Value: value, Ctx: cc.ctx})
+	return err
+}
+
+func (cc cCache) Set(key string, value any) (bool, error) {
+	return cc.flag(CacheCall{Op: "set", Key: key, Value: value, Ctx: cc.ctx})
+}
+
+func (cc cCache) Get(key string) (any, error) {
+	return cc.handler(CacheCall{Op: "get", Key: key, Ctx: cc.ctx})
+}
+
+func (cc cCache) Exists(key string) (bool, error) {
+	return cc.flag(CacheCall{Op: "exists", Key: key, Ctx: cc.ctx})
+}
+
+func (cc cCache) Forget(key string) error {
+	_, err := cc.handler(CacheCall{Op: "forget", Key: key, Ctx: cc.ctx})
+	return err
+}
+
+func (cc cCache) Pull(key string) (any, error) {
+	return cc.handler(CacheCall{Op: "pull", Key: key, Ctx: cc.ctx})
+}
+
+func (cc cCache) TTL(key string) (time.Duration, error) {
+	v, err := cc.handler(CacheCall{Op: "ttl", Key: key, Ctx: cc.ctx})
+	ttl, _ := v.(time.Duration)
+	return ttl, err
+}
+
+func (cc cCache) Cast(key string) (caster.Caster, error) {
+	v, err := cc.Get(key)
+	return caster.NewCaster(v), err
+}
+
+func (cc cCache) IncrementFloat(key string, value float64) (bool, error) {
+	return cc.flag(CacheCall{Op: "increment_float", Key: key, Value: value, Ctx: cc.ctx})
+}
+
+func (cc cCache) Increment(key string, value int64) (bool, error) {
+	return cc.flag(CacheCall{Op: "increment", Key: key, Value: value, Ctx: cc.ctx})
+}
+
+func (cc cCache) DecrementFloat(key string, value float64) (bool, error) {
+	return cc.flag(CacheCall{Op: "decrement_float", Key: key, Value: value, Ctx: cc.ctx})
+}
+
+func (cc cCache) Decrement(key string, value int64) (bool, error) {
+	return cc.flag(CacheCall{Op: "decrement", Key: key, Value: value, Ctx: cc.ctx})
+}
@@ -0,0 +1,194 @@
+package cache_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestChain(t *testing.T) {
+	base := redisCache()
+	calls := make([]string, 0)
+	recorder := func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+		if call.Op != "map_key" {
+			calls = append(calls, call.Op+":"+call.Key)
+		}
+		return next(call)
+	}
+
+	// upper case string values on write
+	upper := func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+		if call.Op == "map_key" {
+			return nil, nil
+		}
+		if s, ok := call.Value.(string); ok && (call.Op == "put" || call.Op == "set") {
+			call.Value = strings.ToUpper(s)
+		}
+		return next(call)
+	}
+
+	c := cache.Chain(base, recorder, cache.KeyPrefix("tenant"), upper)
+	if err := c.Put("test-chain", "value", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := base.Get("tenant-test-chain"); err != nil || v != "VALUE" {
+		t.Fatalf("middlewares must change key and value, get %v %v", v, err)
+	}
+
+	if caster, err := c.Cast("test-chain"); err != nil || caster.StringSafe("") != "VALUE" {
+		t.Fatalf("cast must read through chain %v", err)
+	}
+
+	if exists, err := c.Set("test-chain-missing", "x"); err != nil || exists {
+		t.Fatalf("set missing must return false %v", err)
+	}
+
+	if err := c.Put("test-chain-num", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := c.Increment("test-chain-num", 2); err != nil || !ok {
+		t.Fatalf("increment failed %v", err)
+	} else if v, _ := base.Cast("tenant-test-chain-num"); v.IntSafe(0) != 3 {
+		t.Fatalf("want 3, get %v", v)
+	}
+
+	if ttl, err := c.TTL("test-chain"); err != nil || ttl <= 0 {
+		t.Fatalf("invalid ttl %s %v", ttl, err)
+	}
+
+	want := []string{"put:test-chain", "get:test-chain", "set:test-chain-missing", "put:test-chain-num", "increment:test-chain-num", "ttl:test-chain"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("invalid calls %v", calls)
+	}
+
+	// short circuit
+	readonly := cache.Chain(base, func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+		if call.Op == "put" {
+			return nil, nil
+		}
+		return next(call)
+	})
+
+	if err := readonly.Put("test-chain-readonly", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := base.Exists("test-chain-readonly"); err != nil || exists {
+		t.Fatalf("put must skipped %v", err)
+	}
+
+	// rate limiter on chained cache use prefixed keys
+	limiter, err := cache.NewRateLimiter("test-chain-limiter", 2, time.Minute, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	if allowed, left, _, err := limiter.Attempt(); err != nil || !allowed || left != 1 {
+		t.Fatalf("attempt failed %v %d %v", allowed, left, err)
+	}
+
+	if exists, err := base.Exists("tenant-test-chain-limiter"); err != nil || !exists {
+		t.Fatalf("limiter must use chained keys %v", err)
+	}
+}
+
+func TestChainNativeDrivers(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, base := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		// key only chain keep native driver implementation, limiter not call chain
+		metrics := cache.NewMetrics()
+		c := cache.Chain(base, cache.MetricsMiddleware(name, metrics), cache.KeyPrefix("tenant"))
+		limiter, err := cache.NewRateLimiter("test-native-limiter", 2, time.Minute, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Clear(); err != nil {
+			t.Fatal(err)
+		}
+
+		if allowed, _, _, err := limiter.Attempt(); err != nil || !allowed {
+			t.Fatalf("%s: attempt failed %v", name, err)
+		}
+
+		if name == "redis" && metrics.Count("cache", name, "get", "miss")+metrics.Count("cache", name, "get", "hit") != 0 {
+			t.Fatalf("%s: key only chain must keep native implementation", name)
+		}
+
+		if exists, err := base.Exists("tenant-test-native-limiter"); err != nil || !exists {
+			t.Fatalf("%s: native implementation must use mapped keys %v", name, err)
+		}
+
+		// file lock use mapped key
+		lock, err := cache.NewLock("test-native-lock", time.Minute, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := lock.TryLock(); err != nil || !ok {
+			t.Fatalf("%s: lock failed %v", name, err)
+		}
+
+		if exists, err := base.Exists("tenant-test-native-lock"); err != nil || !exists {
+			t.Fatalf("%s: lock must use mapped key %v", name, err)
+		}
+
+		if ok, err := lock.Unlock(); err != nil || !ok {
+			t.Fatalf("%s: unlock failed %v", name, err)
+		}
+
+		// pass through middlewares keep native driver implementation
+		logged := make([]string, 0)
+		logger := func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+			v, err := next(call)
+			logged = append(logged, call.Op)
+			return v, err
+		}
+		passed := cache.Chain(base, logger, cache.MetricsMiddleware(name, metrics), cache.KeyPrefix("tenant"))
+		lock, err = cache.NewLock("test-native-lock", time.Minute, passed)
+		if err != nil {
+			t.Fatalf("%s: logging chain must keep native driver %v", name, err)
+		}
+
+		if ok, err := lock.TryLock(); err != nil || !ok {
+			t.Fatalf("%s: lock failed %v", name, err)
+		}
+
+		if exists, err := base.Exists("tenant-test-native-lock"); err != nil || !exists {
+			t.Fatalf("%s: lock must use mapped key %v", name, err)
+		}
+
+		if ok, err := lock.Unlock(); err != nil || !ok {
+			t.Fatalf("%s: unlock failed %v", name, err)
+		}
+
+		if len(logged) != 1 || logged[0] != "map_key" {
+			t.Fatalf("%s: native driver must not call chain, get %v", name, logged)
+		}
+
+		// value changing chain use generic operations through chain
+		opaque := cache.Chain(base, cache.MetricsMiddleware(name, metrics), func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+			if call.Op == "map_key" {
+				return nil, nil
+			}
+			return next(call)
+		})
+		limiter, _ = cache.NewRateLimiter("test-native-limiter", 2, time.Minute, opaque)
+		if _, _, _, err := limiter.Attempt(); err != nil {
+			t.Fatal(err)
+		}
+
+		if metrics.Count("cache", name, "get", "miss")+metrics.Count("cache", name, "get", "hit") == 0 {
+			t.Fatalf("%s: opaque chain must use generic operations", name)
+		}
+	}
+}
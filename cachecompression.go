@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gomig/utils"
+)
+
+// CompressionAlgorithm cache value compression algorithm
+type CompressionAlgorithm string
+
+const (
+	// GzipCompression gzip compression
+	GzipCompression CompressionAlgorithm = "gzip"
+	// DeflateCompression raw deflate compression, smaller header than gzip
+	DeflateCompression CompressionAlgorithm = "deflate"
+	// FastCompression raw deflate with best speed level, faster with lower ratio
+	// like snappy style algorithms
+	FastCompression CompressionAlgorithm = "fast"
+)
+
+// max size of decompressed value, larger values rejected for protecting against
+// compression bombs
+const maxDecompressedSize = 64 << 20
+
+type compressionCodec struct {
+	writer func(w io.Writer) (io.WriteCloser, error)
+	reader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var compressionMutex = new(sync.RWMutex)
+var compressionCodecs = map[CompressionAlgorithm]compressionCodec{
+	GzipCompression: {
+		writer: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		reader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	DeflateCompression: {
+		writer: func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.DefaultCompression) },
+		reader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	},
+	FastCompression: {
+		writer: func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.BestSpeed) },
+		reader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	},
+}
+
+// RegisterCompression register compression algorithm, used for adding algorithms
+// from external packages (zstd, snappy, ...). algorithm name must not contains : or /
+func RegisterCompression(
+	algorithm CompressionAlgorithm,
+	writer func(w io.Writer) (io.WriteCloser, error),
+	reader func(r io.Reader) (io.ReadCloser, error),
+) error {
+	if algorithm == "" || strings.ContainsAny(string(algorithm), ":/") || writer == nil || reader == nil {
+		return utils.TaggedError([]string{"Compression"}, "invalid algorithm %q", algorithm)
+	}
+
+	compressionMutex.Lock()
+	defer compressionMutex.Unlock()
+	compressionCodecs[algorithm] = compressionCodec{writer: writer, reader: reader}
+	return nil
+}
+
+type compressor struct {
+	algorithm CompressionAlgorithm
+	threshold int
+}
+
+func (c compressor) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"Compression"}, pattern, params...)
+}
+
+func (c compressor) codec(algorithm CompressionAlgorithm) (compressionCodec, error) {
+	compressionMutex.RLock()
+	defer compressionMutex.RUnlock()
+	if codec, ok := compressionCodecs[algorithm]; ok {
+		return codec, nil
+	}
+	return compressionCodec{}, c.err("unknown algorithm %s", algorithm)
+}
+
+// compress compress string and bytes values larger than threshold as
+// cmp:<algorithm>:<compressed data> for string and cmp:<algorithm>/bytes:<compressed data>
+// for bytes, other values returned as is
+func (c compressor) compress(value any) (any, error) {
+	var raw string
+	kind := ""
+	switch v := value.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+		kind = "/bytes"
+	default:
+		return value, nil
+	}
+
+	// values look like compressed value always compressed to be readable
+	flagged := strings.HasPrefix(raw, "cmp:")
+	if len(raw) < c.threshold && !flagged {
+		return value, nil
+	}
+
+	codec, err := c.codec(c.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBufferString("cmp:" + string(c.algorithm) + kind + ":")
+	w, err := codec.writer(buf)
+	if err != nil {
+		return nil, c.err(err.Error())
+	}
+
+	if _, err := io.WriteString(w, raw); err != nil {
+		return nil, c.err(err.Error())
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, c.err(err.Error())
+	}
+
+	if buf.Len() >= len(raw) && !flagged {
+		return value, nil
+	}
+	return buf.String(), nil
+}
+
+// decompress decompress flagged values (cmp:<algorithm>[/bytes]:) with their original
+// type, flagged values failed to decompress rejected with error. other values returned as is
+func (c compressor) decompress(value any) (any, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, "cmp:") {
+		return value, nil
+	}
+
+	header, data, ok := strings.Cut(strings.TrimPrefix(s, "cmp:"), ":")
+	if !ok {
+		return value, nil
+	}
+
+	algorithm, kind, _ := strings.Cut(header, "/")
+	if algorithm == "" || (kind != "" && kind != "bytes") {
+		return value, nil
+	}
+
+	codec, err := c.codec(CompressionAlgorithm(algorithm))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := codec.reader(strings.NewReader(data))
+	if err != nil {
+		return nil, c.err("invalid %s value: %s", algorithm, err.Error())
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, c.err("invalid %s value: %s", algorithm, err.Error())
+	}
+
+	if int64(len(raw)) > maxDecompressedSize {
+		return nil, c.err("decompressed value exceeds %d bytes", maxDecompressedSize)
+	}
+
+	if kind == "bytes" {
+		return raw, nil
+	}
+	return string(raw), nil
+}
+
+// Compression create middleware compressing string and bytes values larger than
+// threshold bytes. compressed values flagged in stored value and decompressed on
+// read with their algorithm and original type, so algorithm can be changed later.
+// values stored only if compression make them smaller
+func Compression(algorithm CompressionAlgorithm, threshold int) (CacheMiddleware, error) {
+	c := compressor{algorithm: algorithm, threshold: threshold}
+	if _, err := c.codec(algorithm); err != nil {
+		return nil, err
+	}
+
+	return func(call CacheCall, next CacheHandler) (any, error) {
+		switch call.Op {
+		case "put", "put_forever", "set":
+			compressed, err := c.compress(call.Value)
+			if err != nil {
+				return nil, err
+			}
+			call.Value = compressed
+			return next(call)
+		case "get", "pull":
+			v, err := next(call)
+			if err != nil || v == nil {
+				return v, err
+			}
+			return c.decompress(v)
+		case "map_key":
+			// value changing middleware, drivers must use chain
+			return nil, nil
+		default:
+			return next(call)
+		}
+	}, nil
+}
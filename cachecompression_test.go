@@ -0,0 +1,172 @@
+package cache_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestCompression(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	large := strings.Repeat("compressible value ", 100)
+	key, _ := cache.Encryption(cache.EncryptionKey{ID: "k1", Key: []byte("0123456789abcdef0123456789abcdef")})
+
+	bomb := bytes.NewBufferString("cmp:gzip:")
+	w := gzip.NewWriter(bomb)
+	w.Write(make([]byte, 65<<20))
+	w.Close()
+
+	for name, base := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		gzipped, err := cache.Compression(cache.GzipCompression, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := cache.Chain(base, gzipped)
+
+		if err := c.Put("test-compressed", large, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if raw, err := base.Cast("test-compressed"); err != nil {
+			t.Fatal(err)
+		} else if v := raw.StringSafe(""); !strings.HasPrefix(v, "cmp:gzip:") || len(v) >= len(large) {
+			t.Fatalf("%s: large value must stored compressed", name)
+		}
+
+		if v, err := c.Get("test-compressed"); err != nil || v != large {
+			t.Fatalf("%s: invalid decompressed value %v", name, err)
+		}
+
+		// small values stored as is
+		if err := c.Put("test-small", "small", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if raw, _ := base.Cast("test-small"); raw.StringSafe("") != "small" {
+			t.Fatalf("%s: small value must stored as is", name)
+		}
+
+		if err := c.PutForever("test-counter", 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := c.Increment("test-counter", 2); err != nil || !ok {
+			t.Fatalf("%s: increment failed %v", name, err)
+		}
+
+		// values look like compressed values always flagged
+		if err := c.Put("test-flag", "cmp:gzip:not compressed", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := c.Get("test-flag"); err != nil || v != "cmp:gzip:not compressed" {
+			t.Fatalf("%s: invalid flag like value %v %v", name, v, err)
+		}
+
+		// readers decompress values of other algorithms
+		deflated, _ := cache.Compression(cache.DeflateCompression, 256)
+		if v, err := cache.Chain(base, deflated).Pull("test-compressed"); err != nil || v != large {
+			t.Fatalf("%s: gzip value must read by deflate middleware %v", name, err)
+		}
+
+		// bytes values keep their type
+		if err := c.Put("test-bytes", []byte(large), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if raw, _ := base.Cast("test-bytes"); !strings.HasPrefix(raw.StringSafe(""), "cmp:gzip/bytes:") {
+			t.Fatalf("%s: large bytes value must stored compressed", name)
+		}
+
+		if v, err := c.Get("test-bytes"); err != nil {
+			t.Fatal(err)
+		} else if b, ok := v.([]byte); !ok || string(b) != large {
+			t.Fatalf("%s: want []byte value, get %T", name, v)
+		}
+
+		// unflagged values returned as is
+		for _, plain := range []string{"cmp:plain", "cmp::plain", "cmp:gzip/text:plain"} {
+			if err := base.Put("test-plain", plain, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			if v, err := c.Get("test-plain"); err != nil || v != plain {
+				t.Fatalf("%s: plain value must returned as is, get %v %v", name, v, err)
+			}
+		}
+
+		// flagged values failed to decompress rejected
+		for _, corrupt := range []string{"cmp:gzip:plain", "cmp:deflate/bytes:plain", "cmp:unknown:plain"} {
+			if err := base.Put("test-plain", corrupt, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			if v, err := c.Get("test-plain"); err == nil {
+				t.Fatalf("%s: corrupt %s value must fail, get %v", name, corrupt, v)
+			}
+		}
+
+		// decompressed size limited
+		if err := base.Put("test-bomb", bomb.String(), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Get("test-bomb"); err == nil {
+			t.Fatalf("%s: large decompressed value must fail", name)
+		}
+
+		// compress before encrypt
+		secure := cache.Chain(base, gzipped, key)
+		if err := secure.Put("test-secure", large, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := secure.Get("test-secure"); err != nil || v != large {
+			t.Fatalf("%s: invalid compressed encrypted value %v", name, err)
+		}
+
+		for _, k := range []string{"test-compressed", "test-small", "test-counter", "test-flag", "test-secure", "test-bytes", "test-plain", "test-bomb"} {
+			base.Forget(k)
+		}
+	}
+
+	if _, err := cache.Compression("brotli", 0); err == nil {
+		t.Fatal("unknown algorithm must fail")
+	}
+
+	// external algorithms
+	err := cache.RegisterCompression(
+		"test-gzip",
+		func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, algorithm := range []cache.CompressionAlgorithm{cache.FastCompression, "test-gzip"} {
+		middleware, err := cache.Compression(algorithm, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := cache.Chain(fileCache(), middleware)
+		if err := c.Put("test-algorithm", large, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := c.Pull("test-algorithm"); err != nil || v != large {
+			t.Fatalf("%s: invalid decompressed value %v", algorithm, err)
+		}
+	}
+
+	if err := cache.RegisterCompression("bad:name", nil, nil); err == nil {
+		t.Fatal("invalid algorithm must fail")
+	}
+}
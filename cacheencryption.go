@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"strings"
+
+	"github.com/gomig/utils"
+)
+
+// EncryptionKey aes key identified by id, key must be 16, 24 or 32 bytes
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+type encryptor struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+func (e encryptor) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"Encryption"}, pattern, params...)
+}
+
+// encryptedValue wrapper for gob encoding non string values before sealing
+type encryptedValue struct {
+	Data any
+}
+
+// encrypt encrypt string values as enc:<key id>:<base64 nonce and cipher text>
+// and other values as enc:<key id>:gob:<base64 nonce and cipher text> of gob
+// encoded value. numeric values are counters and returned as is. cache key used
+// as additional data so value not readable under other key
+func (e encryptor) encrypt(key string, value any) (any, error) {
+	var plain []byte
+	kind := ""
+	switch v := value.(type) {
+	case string:
+		plain = []byte(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value, nil
+	default:
+		b := bytes.Buffer{}
+		if err := gob.NewEncoder(&b).Encode(encryptedValue{Data: v}); err != nil {
+			return nil, e.err("encode %s failed: %s", key, err.Error())
+		}
+		plain = b.Bytes()
+		kind = "gob:"
+	}
+
+	aead := e.aeads[e.active]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", e.err(err.Error())
+	}
+
+	sealed := aead.Seal(nonce, nonce, plain, []byte(key+":"+kind))
+	return "enc:" + e.active + ":" + kind + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt decrypt encrypted value with its key and return original value type,
+// non encrypted values returned as is
+func (e encryptor) decrypt(key string, value any) (any, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, "enc:") {
+		return value, nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(s, "enc:"), ":")
+	if !ok {
+		return nil, e.err("invalid encrypted value of %s", key)
+	}
+
+	kind := ""
+	if strings.HasPrefix(encoded, "gob:") {
+		kind, encoded = "gob:", strings.TrimPrefix(encoded, "gob:")
+	}
+
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, e.err("unknown key %s for %s", id, key)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, e.err("invalid encrypted value of %s", key)
+	}
+
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(key+":"+kind))
+	if err != nil {
+		return nil, e.err("decrypt %s failed", key)
+	}
+
+	if kind == "" {
+		return string(plain), nil
+	}
+
+	var v encryptedValue
+	if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&v); err != nil {
+		return nil, e.err("decode %s failed: %s", key, err.Error())
+	}
+	return v.Data, nil
+}
+
+// Encryption create middleware encrypting stored values with AES-GCM. first key
+// used for encrypting and all keys used for decrypting, so keys rotated by adding
+// new key as first key and values re-encrypted with new key on write. values
+// decrypted with their original type, numeric values (counters) and increment
+// and decrement operations not encrypted and non encrypted values returned as is
+func Encryption(keys ...EncryptionKey) (CacheMiddleware, error) {
+	e := encryptor{aeads: make(map[string]cipher.AEAD)}
+	if len(keys) == 0 {
+		return nil, e.err("no encryption key")
+	}
+
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, e.err("invalid key id %q", k.ID)
+		}
+
+		if _, ok := e.aeads[k.ID]; ok {
+			return nil, e.err("duplicate key id %s", k.ID)
+		}
+
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, e.err("invalid key %s: %s", k.ID, err.Error())
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, e.err(err.Error())
+		}
+		e.aeads[k.ID] = aead
+	}
+	e.active = keys[0].ID
+
+	return func(call CacheCall, next CacheHandler) (any, error) {
+		switch call.Op {
+		case "put", "put_forever", "set":
+			encrypted, err := e.encrypt(call.Key, call.Value)
+			if err != nil {
+				return nil, err
+			}
+			call.Value = encrypted
+			return next(call)
+		case "get", "pull":
+			v, err := next(call)
+			if err != nil || v == nil {
+				return v, err
+			}
+			return e.decrypt(call.Key, v)
+		case "map_key":
+			// value changing middleware, drivers must use chain
+			return nil, nil
+		default:
+			return next(call)
+		}
+	}, nil
+}
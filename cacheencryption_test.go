@@ -0,0 +1,122 @@
+package cache_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestEncryption(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	oldKey := cache.EncryptionKey{ID: "k1", Key: []byte("0123456789abcdef0123456789abcdef")}
+	newKey := cache.EncryptionKey{ID: "k2", Key: []byte("fedcba9876543210fedcba9876543210")}
+
+	for name, base := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		oldMiddleware, err := cache.Encryption(oldKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		old := cache.Chain(base, oldMiddleware)
+
+		if err := old.Put("test-encrypted", "john@example.com", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if raw, err := base.Cast("test-encrypted"); err != nil {
+			t.Fatal(err)
+		} else if v := raw.StringSafe(""); !strings.HasPrefix(v, "enc:k1:") || strings.Contains(v, "john") {
+			t.Fatalf("%s: value must stored encrypted, get %s", name, v)
+		}
+
+		if v, err := old.Get("test-encrypted"); err != nil || v != "john@example.com" {
+			t.Fatalf("%s: invalid decrypted value %v %v", name, v, err)
+		}
+
+		// rotation
+		rotatedMiddleware, err := cache.Encryption(newKey, oldKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotated := cache.Chain(base, rotatedMiddleware)
+
+		if v, err := rotated.Get("test-encrypted"); err != nil || v != "john@example.com" {
+			t.Fatalf("%s: old key value must decrypted after rotation %v %v", name, v, err)
+		}
+
+		if ok, err := rotated.Set("test-encrypted", "jane@example.com"); err != nil || !ok {
+			t.Fatalf("%s: set failed %v", name, err)
+		}
+
+		if raw, _ := base.Cast("test-encrypted"); !strings.HasPrefix(raw.StringSafe(""), "enc:k2:") {
+			t.Fatalf("%s: value must re-encrypted with new key", name)
+		}
+
+		if _, err := old.Get("test-encrypted"); err == nil {
+			t.Fatalf("%s: removed key must fail", name)
+		}
+
+		// value bound to cache key
+		raw, _ := base.Get("test-encrypted")
+		if err := base.Put("test-encrypted-copy", raw, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rotated.Get("test-encrypted-copy"); err == nil {
+			t.Fatalf("%s: copied value must fail", name)
+		}
+
+		// non string values keep their type
+		if err := rotated.Put("test-encrypted-bytes", []byte("secret"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if raw, _ := base.Cast("test-encrypted-bytes"); !strings.HasPrefix(raw.StringSafe(""), "enc:k2:gob:") {
+			t.Fatalf("%s: bytes value must stored encrypted", name)
+		}
+
+		if v, err := rotated.Get("test-encrypted-bytes"); err != nil {
+			t.Fatal(err)
+		} else if b, ok := v.([]byte); !ok || string(b) != "secret" {
+			t.Fatalf("%s: want []byte secret, get %T %v", name, v, v)
+		}
+
+		if err := rotated.Put("test-encrypted-bool", true, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := rotated.Get("test-encrypted-bool"); err != nil || v != true {
+			t.Fatalf("%s: want bool true, get %T %v %v", name, v, v, err)
+		}
+
+		// counters stored as is and increments passed through
+		if err := rotated.Put("test-encrypted-num", 5, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := rotated.Increment("test-encrypted-num", 2); err != nil || !ok {
+			t.Fatalf("%s: increment failed %v", name, err)
+		}
+
+		if ok, err := rotated.DecrementFloat("test-encrypted-num", 0.5); err != nil || !ok {
+			t.Fatalf("%s: decrement failed %v", name, err)
+		}
+
+		if v, err := rotated.Cast("test-encrypted-num"); err != nil || v.Float64Safe(0) != 6.5 {
+			t.Fatalf("%s: want 6.5, get %v %v", name, v, err)
+		}
+
+		if raw, _ := base.Cast("test-encrypted-num"); raw.Float64Safe(0) != 6.5 {
+			t.Fatalf("%s: counter must stored as is", name)
+		}
+
+		for _, k := range []string{"test-encrypted", "test-encrypted-copy", "test-encrypted-bytes", "test-encrypted-bool", "test-encrypted-num"} {
+			base.Forget(k)
+		}
+	}
+
+	if _, err := cache.Encryption(cache.EncryptionKey{ID: "bad", Key: []byte("short")}); err == nil {
+		t.Fatal("invalid key must fail")
+	}
+}
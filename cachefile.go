@@ -4,7 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
-	"math"
+	"math/rand"
 	"os"
 	"path"
 	"time"
@@ -87,6 +87,108 @@ func (rc fCache) write(key string, record record) error {
 	return nil
 }
 
+// fileGuardStale age of guard file left by crashed process
+const fileGuardStale = 10 * time.Second
+
+// guard acquire key guard file, exclusive across processes sharing cache dir.
+// guard of crashed process broken after fileGuardStale. return release function
+func (rc fCache) guard(key string) (func(), error) {
+	if err := utils.CreateDirectory(rc.dir); err != nil {
+		return nil, rc.err(err.Error())
+	}
+
+	name := rc.hashPath(key) + ".guard"
+	deadline := time.Now().Add(2 * fileGuardStale)
+	for {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		} else if !errors.Is(err, os.ErrExist) {
+			return nil, rc.err(err.Error())
+		}
+
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > fileGuardStale {
+			rc.breakGuard(name)
+		} else if time.Now().After(deadline) {
+			return nil, rc.err("guard of %s not released", key)
+		}
+		time.Sleep(time.Millisecond + time.Duration(rand.Int63n(int64(time.Millisecond))))
+	}
+}
+
+// breakGuard remove stale guard file. guard moved to unique name and restored if
+// renewed by other process after stat
+func (rc fCache) breakGuard(name string) {
+	suffix, err := utils.RandomStringFromCharset(10, "0123456789abcdefghijklmnopqrstuvwxyz")
+	if err != nil {
+		return
+	}
+
+	moved := name + "." + suffix
+	if err := os.Rename(name, moved); err != nil {
+		return
+	}
+	defer os.Remove(moved)
+
+	if info, err := os.Stat(moved); err == nil && time.Since(info.ModTime()) <= fileGuardStale {
+		os.Link(moved, name)
+	}
+}
+
+// commit write record atomically by rename or delete key file for nil record
+func (rc fCache) commit(key string, rec *record) error {
+	if rec == nil {
+		return rc.delete(key)
+	}
+
+	encoded, err := rec.Serialize()
+	if err != nil {
+		return rc.err(err.Error())
+	}
+
+	temp := rc.hashPath(key) + ".tmp"
+	if err := os.WriteFile(temp, []byte(encoded), 0644); err != nil {
+		return rc.err(err.Error())
+	}
+
+	if err := os.Rename(temp, rc.hashPath(key)); err != nil {
+		os.Remove(temp)
+		return rc.err(err.Error())
+	}
+	return nil
+}
+
+// create write record only if key not exists or expired, safe across processes
+// sharing cache dir
+func (rc fCache) create(key string, rec record) (bool, error) {
+	release, err := rc.guard(key)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if current, err := rc.read(key); err != nil || current != nil {
+		return false, err
+	}
+	return true, rc.commit(key, &rec)
+}
+
+// swap replace record or delete key for nil record only if current record data
+// equal to old, safe across processes sharing cache dir
+func (rc fCache) swap(key string, old any, rec *record) (bool, error) {
+	release, err := rc.guard(key)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if current, err := rc.read(key); err != nil || current == nil || current.Data != old {
+		return false, err
+	}
+	return true, rc.commit(key, rec)
+}
+
 func (rc fCache) Put(key string, value any, ttl time.Duration) error {
 	rec := record{
 		TTL:  time.Now().UTC().Add(ttl),
@@ -96,8 +198,9 @@ func (rc fCache) Put(key string, value any, ttl time.Duration) error {
 }
 
 func (rc fCache) PutForever(key string, value any) error {
+	// far future time, time.Unix(math.MaxInt64, 0) overflow and compare as past
 	rec := record{
-		TTL:  time.Unix(math.MaxInt64, 0),
+		TTL:  time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
 		Data: value,
 	}
 	return rc.write(key, rec)
@@ -124,7 +227,7 @@ func (rc fCache) Get(key string) (any, error) {
 
 func (rc fCache) Exists(key string) (bool, error) {
 	rec, err := rc.read(key)
-	return rec == nil, err
+	return rec != nil, err
 }
 
 func (rc fCache) Forget(key string) error {
@@ -200,3 +303,13 @@ func (rc fCache) Decrement(key string, value int64) (bool, error) {
 		}
 	}
 }
+
+// fileOf get file cache and mapped key if cache is file driver, used by drivers
+// with native file implementation
+func fileOf(c Cache, key string) (*fCache, string) {
+	inner, mapper := unwrapCache(c)
+	if fc, ok := inner.(*fCache); ok {
+		return fc, mapper(key)
+	}
+	return nil, key
+}
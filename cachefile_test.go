@@ -29,6 +29,22 @@ func TestFileCachePut(t *testing.T) {
 	}
 }
 
+func TestFileCachePutForever(t *testing.T) {
+	err := fileCache().PutForever("name", "kim")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := fileCache().Get("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v != "kim" {
+		t.Fatalf("failed put forever %v", v)
+	}
+}
+
 func TestFileCacheSet(t *testing.T) {
 	exists, err := fileCache().Set("non-exists", "Bla")
 	if err != nil {
@@ -63,6 +79,31 @@ func TestFileCacheSet(t *testing.T) {
 	}
 }
 
+func TestFileCacheExists(t *testing.T) {
+	err := fileCache().Put("name", "kim", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := fileCache().Exists("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists {
+		t.Fatal("failed exists check")
+	}
+
+	exists, err = fileCache().Exists("non-exists")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exists {
+		t.Fatal("failed non exists check")
+	}
+}
+
 func TestFileCacheForget(t *testing.T) {
 	err := fileCache().Put("name", "kim", time.Minute)
 	if err != nil {
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CacheOperation cache operation info passed to hooks
+type CacheOperation struct {
+	// Op operation name (get, put, increment, ...)
+	Op string
+	// Key operation key
+	Key string
+	// Start operation start time
+	Start time.Time
+	// Duration operation duration, set after operation
+	Duration time.Duration
+	// Result operation result (ok, error, hit, miss), set after operation
+	Result string
+	// Err operation error, set after operation
+	Err error
+	// Ctx operation context bound by WithContext, context.Background() if not bound
+	Ctx context.Context
+}
+
+// CacheHook function called before cache operation, returned function called
+// after operation if not nil
+type CacheHook func(op CacheOperation) func(op CacheOperation)
+
+// HooksMiddleware create middleware calling hooks around cache calls
+func HooksMiddleware(hooks ...CacheHook) CacheMiddleware {
+	return func(call CacheCall, next CacheHandler) (any, error) {
+		if call.Op == "map_key" {
+			return keyMapper(nil), nil
+		}
+
+		op := CacheOperation{Op: call.Op, Key: call.Key, Start: time.Now(), Ctx: call.Ctx}
+		if op.Ctx == nil {
+			op.Ctx = context.Background()
+		}
+		afters := make([]func(CacheOperation), 0, len(hooks))
+		for _, hook := range hooks {
+			if after := hook(op); after != nil {
+				afters = append(afters, after)
+			}
+		}
+
+		v, err := next(call)
+		op.Duration = time.Since(op.Start)
+		op.Result = callResult(call, v, err)
+		op.Err = err
+		for i := len(afters) - 1; i >= 0; i-- {
+			afters[i](op)
+		}
+		return v, err
+	}
+}
+
+// globPattern compile glob pattern to regexp, * match any characters (including /)
+// and ? match single character
+func globPattern(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// RedactKeys wrap hook to replace keys matching glob patterns with "[redacted]".
+// * match any characters including / and : separators, ? match single character
+func RedactKeys(hook CacheHook, patterns ...string) CacheHook {
+	globs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		globs = append(globs, globPattern(p))
+	}
+
+	redact := func(op CacheOperation) CacheOperation {
+		for _, glob := range globs {
+			if glob.MatchString(op.Key) {
+				op.Key = "[redacted]"
+				break
+			}
+		}
+		return op
+	}
+
+	return func(op CacheOperation) func(op CacheOperation) {
+		after := hook(redact(op))
+		if after == nil {
+			return nil
+		}
+		return func(op CacheOperation) {
+			after(redact(op))
+		}
+	}
+}
+
+// Tracer opentelemetry style tracer. opentelemetry trace.Tracer can be used by
+// otelcache adapter package
+type Tracer interface {
+	// Start create span as child of context span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span opentelemetry style span
+type Span interface {
+	// SetAttribute set span attribute
+	SetAttribute(key string, value any)
+	// RecordError record error and mark span as failed
+	RecordError(err error)
+	// End end span
+	End()
+}
+
+// SpanHook create hook recording each operation as "cache.<op>" span child of
+// operation context span with cache.operation, cache.key and cache.result attributes.
+// bind request context to cache by WithContext
+func SpanHook(tracer Tracer) CacheHook {
+	return func(op CacheOperation) func(op CacheOperation) {
+		_, span := tracer.Start(op.Ctx, "cache."+op.Op)
+		span.SetAttribute("cache.operation", op.Op)
+		span.SetAttribute("cache.key", op.Key)
+		return func(op CacheOperation) {
+			span.SetAttribute("cache.result", op.Result)
+			if op.Err != nil {
+				span.RecordError(op.Err)
+			}
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+type testSpan struct {
+	name  string
+	ctx   context.Context
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)              { s.err = err }
+func (s *testSpan) End()                               { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, cache.Span) {
+	span := &testSpan{name: name, ctx: ctx, attrs: make(map[string]any)}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestCacheHooks(t *testing.T) {
+	tracer := new(testTracer)
+	order := make([]string, 0)
+	logger := func(op cache.CacheOperation) func(op cache.CacheOperation) {
+		order = append(order, "before-"+op.Op)
+		return func(op cache.CacheOperation) {
+			order = append(order, "after-"+op.Op+"-"+op.Result)
+		}
+	}
+
+	c := cache.NewHookedCache(
+		redisCache(),
+		logger,
+		cache.RedactKeys(cache.SpanHook(tracer), "*verification*"),
+	)
+
+	if err := c.Put("test-hook", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("phone-verification-john"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Increment("test-hook", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("want 3 spans, get %d", len(tracer.spans))
+	}
+
+	put, get := tracer.spans[0], tracer.spans[1]
+	if put.name != "cache.put" || put.attrs["cache.key"] != "test-hook" || put.attrs["cache.result"] != "ok" || !put.ended {
+		t.Fatalf("invalid put span %v", put)
+	}
+
+	if get.name != "cache.get" || get.attrs["cache.key"] != "[redacted]" || get.attrs["cache.result"] != "miss" {
+		t.Fatalf("invalid redacted span %v", get)
+	}
+
+	want := []string{"before-put", "after-put-ok", "before-get", "after-get-miss", "before-increment", "after-increment-hit"}
+	if len(order) != len(want) {
+		t.Fatalf("invalid hooks order %v", order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("invalid hooks order %v", order)
+		}
+	}
+
+	// errors recorded on span
+	if _, err := c.Increment("test-hook-missing-str", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("test-hook-str", "text", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Increment("test-hook-str", 1); err == nil {
+		t.Fatal("increment text must fail")
+	} else if span := tracer.spans[len(tracer.spans)-1]; span.err == nil || span.attrs["cache.result"] != "error" {
+		t.Fatalf("span must record error %v", span)
+	}
+}
+
+type testCtxKey struct{}
+
+func TestCacheHooksContext(t *testing.T) {
+	tracer := new(testTracer)
+	hooked := cache.NewHookedCache(redisCache(), cache.RedactKeys(cache.SpanHook(tracer), "*verification*"))
+	chained := cache.Chain(hooked, cache.KeyPrefix("tenant"))
+
+	// operations without bound context use background context
+	if _, err := chained.Get("test-hook-ctx"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []string{"req-1", "req-2"} {
+		ctx := context.WithValue(context.Background(), testCtxKey{}, req)
+		if _, err := cache.WithContext(chained, ctx).Get("users/12/verification"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("want 3 spans, get %d", len(tracer.spans))
+	}
+
+	if tracer.spans[0].ctx == nil || tracer.spans[0].ctx.Value(testCtxKey{}) != nil {
+		t.Fatal("unbound operation must use background context")
+	}
+
+	for i, req := range []string{"req-1", "req-2"} {
+		span := tracer.spans[i+1]
+		if span.ctx.Value(testCtxKey{}) != req {
+			t.Fatalf("span %d must be child of %s context", i+1, req)
+		}
+		if span.attrs["cache.key"] != "[redacted]" {
+			t.Fatalf("keys with / must redacted, get %v", span.attrs["cache.key"])
+		}
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"time"
 
 	"github.com/gomig/caster"
@@ -10,17 +11,73 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// RedisCacheOption redis cache option
+type RedisCacheOption func(*rCache)
+
+// RedisTimeout set timeout of each redis operation. context timeout enabled on
+// redis client, so timeout applied to socket reads and writes too
+func RedisTimeout(timeout time.Duration) RedisCacheOption {
+	return func(rc *rCache) {
+		rc.timeout = timeout
+	}
+}
+
+// RedisRetry retry idempotent operations (put, set, get, exists, forget, ttl) on
+// connection and timeout errors. retries wait random duration up to backoff
+// doubled per retry and limited to maxBackoff. redis client retries disabled,
+// so operations not retried twice and non idempotent operations never retried
+func RedisRetry(retries uint, backoff, maxBackoff time.Duration) RedisCacheOption {
+	return func(rc *rCache) {
+		rc.retries = retries
+		rc.backoff = backoff
+		rc.maxBackoff = max(backoff, maxBackoff)
+	}
+}
+
+// RedisCircuitBreaker open circuit after threshold consecutive connection failures
+// and fail fast until cooldown passed. after cooldown one trial operation allowed,
+// success close and failure re-open circuit. onChange called on state changes
+func RedisCircuitBreaker(threshold uint32, cooldown time.Duration, onChange func(from, to CircuitState)) RedisCacheOption {
+	return func(rc *rCache) {
+		rc.breaker = new(cBreaker)
+		rc.breaker.init(threshold, cooldown, onChange)
+	}
+}
+
+// RedisFallback use fallback cache while circuit is open, used with RedisCircuitBreaker only
+func RedisFallback(fallback Cache) RedisCacheOption {
+	return func(rc *rCache) {
+		rc.fallback = fallback
+	}
+}
+
 type rCache struct {
-	prefix string
-	client *redis.Client
+	prefix     string
+	client     *redis.Client
+	timeout    time.Duration
+	retries    uint
+	backoff    time.Duration
+	maxBackoff time.Duration
+	breaker    *cBreaker
+	fallback   Cache
 }
 
 func (rc rCache) err(pattern string, params ...any) error {
 	return utils.TaggedError([]string{"RedisCache"}, pattern, params...)
 }
 
-func (rc *rCache) init(prefix string, opt redis.Options) {
+func (rc *rCache) init(prefix string, opt redis.Options, options ...RedisCacheOption) {
 	rc.prefix = prefix
+	for _, option := range options {
+		option(rc)
+	}
+
+	if rc.timeout > 0 {
+		opt.ContextTimeoutEnabled = true
+	}
+	if rc.retries > 0 {
+		opt.MaxRetries = -1
+	}
 	rc.client = redis.NewClient(&opt)
 }
 
@@ -28,25 +85,78 @@ func (rc rCache) perfixer(key string) string {
 	return utils.ConcatStr("-", rc.prefix, key)
 }
 
+// unavailable check if error is connection or timeout error and not redis reply
+func (rc rCache) unavailable(err error) bool {
+	var reply redis.Error
+	return err != nil && !errors.As(err, &reply)
+}
+
+// wait get jittered backoff of retry
+func (rc rCache) wait(retry uint) time.Duration {
+	backoff := rc.backoff << min(retry, 32)
+	if backoff <= 0 || backoff > rc.maxBackoff {
+		backoff = rc.maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// exec run redis command with operation timeout, idempotent commands retried on
+// connection errors. command rejected if circuit is open
+func (rc rCache) exec(idempotent bool, cmd func(ctx context.Context) error) error {
+	if rc.breaker != nil && !rc.breaker.allow() {
+		return errors.New("circuit breaker is open")
+	}
+
+	var err error
+	for retry := uint(0); ; retry++ {
+		ctx, cancel := context.TODO(), func() {}
+		if rc.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, rc.timeout)
+		}
+		err = cmd(ctx)
+		cancel()
+
+		if !idempotent || retry >= rc.retries || !rc.unavailable(err) {
+			break
+		}
+		time.Sleep(rc.wait(retry))
+	}
+
+	if rc.breaker != nil {
+		rc.breaker.report(rc.unavailable(err))
+	}
+	return err
+}
+
+// fallbackMiddleware run calls on fallback cache while circuit is open
+func (rc rCache) fallbackMiddleware() CacheMiddleware {
+	fallback := cCache{cache: rc.fallback}
+	return func(call CacheCall, next CacheHandler) (any, error) {
+		if rc.breaker.rejects() {
+			return fallback.execute(call)
+		}
+
+		v, err := next(call)
+		if err != nil && rc.breaker.rejects() {
+			return fallback.execute(call)
+		}
+		return v, err
+	}
+}
+
 func (rc rCache) Put(key string, value any, ttl time.Duration) error {
-	if err := rc.client.SetEx(
-		context.TODO(),
-		rc.perfixer(key),
-		value,
-		ttl,
-	).Err(); err != nil {
+	if err := rc.exec(true, func(ctx context.Context) error {
+		return rc.client.SetEx(ctx, rc.perfixer(key), value, ttl).Err()
+	}); err != nil {
 		return rc.err(err.Error())
 	}
 	return nil
 }
 
 func (rc rCache) PutForever(key string, value any) error {
-	if err := rc.client.Set(
-		context.TODO(),
-		rc.perfixer(key),
-		value,
-		0,
-	).Err(); err != nil {
+	if err := rc.exec(true, func(ctx context.Context) error {
+		return rc.client.Set(ctx, rc.perfixer(key), value, 0).Err()
+	}); err != nil {
 		return rc.err(err.Error())
 	}
 	return nil
@@ -58,12 +168,9 @@ func (rc rCache) Set(key string, value any) (bool, error) {
 		return false, err
 	}
 
-	err = rc.client.Set(
-		context.TODO(),
-		rc.perfixer(key),
-		value,
-		redis.KeepTTL,
-	).Err()
+	err = rc.exec(true, func(ctx context.Context) error {
+		return rc.client.Set(ctx, rc.perfixer(key), value, redis.KeepTTL).Err()
+	})
 
 	if err != nil {
 		err = rc.err(err.Error())
@@ -73,27 +180,31 @@ func (rc rCache) Set(key string, value any) (bool, error) {
 }
 
 func (rc rCache) Get(key string) (any, error) {
-	v, err := rc.client.Get(
-		context.TODO(),
-		rc.perfixer(key),
-	).Result()
+	var v string
+	err := rc.exec(true, func(ctx context.Context) error {
+		var err error
+		v, err = rc.client.Get(ctx, rc.perfixer(key)).Result()
+		return err
+	})
 
 	if errors.Is(err, redis.Nil) {
 		return nil, nil
 	}
 
 	if err != nil {
-		err = rc.err(err.Error())
+		return nil, rc.err(err.Error())
 	}
 
-	return v, err
+	return v, nil
 }
 
 func (rc rCache) Exists(key string) (bool, error) {
-	if exists, err := rc.client.Exists(
-		context.TODO(),
-		rc.perfixer(key),
-	).Result(); err != nil {
+	var exists int64
+	if err := rc.exec(true, func(ctx context.Context) error {
+		var err error
+		exists, err = rc.client.Exists(ctx, rc.perfixer(key)).Result()
+		return err
+	}); err != nil {
 		return false, rc.err(err.Error())
 	} else {
 		return exists > 0, nil
@@ -101,10 +212,9 @@ func (rc rCache) Exists(key string) (bool, error) {
 }
 
 func (rc rCache) Forget(key string) error {
-	if err := rc.client.Del(
-		context.TODO(),
-		rc.perfixer(key),
-	).Err(); err != nil && !errors.Is(err, redis.Nil) {
+	if err := rc.exec(true, func(ctx context.Context) error {
+		return rc.client.Del(ctx, rc.perfixer(key)).Err()
+	}); err != nil && !errors.Is(err, redis.Nil) {
 		return rc.err(err.Error())
 	}
 	return nil
@@ -119,10 +229,12 @@ func (rc rCache) Pull(key string) (any, error) {
 }
 
 func (rc rCache) TTL(key string) (time.Duration, error) {
-	if ttl, err := rc.client.TTL(
-		context.TODO(),
-		rc.perfixer(key),
-	).Result(); err != nil {
+	var ttl time.Duration
+	if err := rc.exec(true, func(ctx context.Context) error {
+		var err error
+		ttl, err = rc.client.PTTL(ctx, rc.perfixer(key)).Result()
+		return err
+	}); err != nil {
 		return 0, rc.err(err.Error())
 	} else {
 		return ttl, nil
@@ -140,11 +252,9 @@ func (rc rCache) IncrementFloat(key string, value float64) (bool, error) {
 		return exists, err
 	}
 
-	err = rc.client.IncrByFloat(
-		context.TODO(),
-		rc.perfixer(key),
-		value,
-	).Err()
+	err = rc.exec(false, func(ctx context.Context) error {
+		return rc.client.IncrByFloat(ctx, rc.perfixer(key), value).Err()
+	})
 	if err != nil {
 		err = rc.err(err.Error())
 	}
@@ -157,11 +267,9 @@ func (rc rCache) Increment(key string, value int64) (bool, error) {
 		return exists, err
 	}
 
-	err = rc.client.IncrBy(
-		context.TODO(),
-		rc.perfixer(key),
-		value,
-	).Err()
+	err = rc.exec(false, func(ctx context.Context) error {
+		return rc.client.IncrBy(ctx, rc.perfixer(key), value).Err()
+	})
 	if err != nil {
 		err = rc.err(err.Error())
 	}
@@ -174,11 +282,9 @@ func (rc rCache) DecrementFloat(key string, value float64) (bool, error) {
 		return exists, err
 	}
 
-	err = rc.client.IncrByFloat(
-		context.TODO(),
-		rc.perfixer(key),
-		-value,
-	).Err()
+	err = rc.exec(false, func(ctx context.Context) error {
+		return rc.client.IncrByFloat(ctx, rc.perfixer(key), -value).Err()
+	})
 	if err != nil {
 		err = rc.err(err.Error())
 	}
@@ -191,13 +297,44 @@ func (rc rCache) Decrement(key string, value int64) (bool, error) {
 		return exists, err
 	}
 
-	err = rc.client.DecrBy(
-		context.TODO(),
-		rc.perfixer(key),
-		value,
-	).Err()
+	err = rc.exec(false, func(ctx context.Context) error {
+		return rc.client.DecrBy(ctx, rc.perfixer(key), value).Err()
+	})
 	if err != nil {
 		err = rc.err(err.Error())
 	}
 	return true, err
 }
+
+// do run native driver command with cache timeout, retry and circuit breaker
+func (rc rCache) do(idempotent bool, cmd func(ctx context.Context, client *redis.Client) error) error {
+	return rc.exec(idempotent, func(ctx context.Context) error {
+		return cmd(ctx, rc.client)
+	})
+}
+
+// run run native driver script with cache timeout and circuit breaker, scripts
+// not retried
+func (rc rCache) run(script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	var cmd *redis.Cmd
+	err := rc.exec(false, func(ctx context.Context) error {
+		cmd = script.Run(ctx, rc.client, keys, args...)
+		return cmd.Err()
+	})
+	if cmd == nil {
+		cmd = redis.NewCmd(context.TODO())
+		cmd.SetErr(err)
+	}
+	return cmd
+}
+
+// redisOf get redis cache and prefixed key if cache is redis driver, used by
+// drivers with native redis implementation. native commands must run with do
+// or run for applying cache timeout, retry and circuit breaker
+func redisOf(c Cache, key string) (*rCache, string) {
+	inner, mapper := unwrapCache(c)
+	if rc, ok := inner.(*rCache); ok {
+		return rc, rc.perfixer(mapper(key))
+	}
+	return nil, key
+}
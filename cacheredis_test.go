@@ -1,7 +1,14 @@
 package cache_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -134,6 +141,23 @@ func TestRedisCacheTTL(t *testing.T) {
 	}
 }
 
+func TestRedisCacheTTLPrecision(t *testing.T) {
+	err := redisCache().Put("name", "kim", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	ttl, err := redisCache().TTL("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl <= time.Second || ttl > 1800*time.Millisecond {
+		t.Fatalf("ttl must have millisecond precision, get %s", ttl)
+	}
+}
+
 func TestRedisCacheIncDecFloat(t *testing.T) {
 	err := redisCache().Put("float-val", 10.1, time.Minute)
 	if err != nil {
@@ -219,3 +243,207 @@ func TestRedisCacheIncDec(t *testing.T) {
 		t.Fatal("failed decrement")
 	}
 }
+
+// flakyRedis redis options with dialer failing while down and counting dials
+func flakyRedis(down *atomic.Bool, dials *atomic.Int32) redis.Options {
+	return redis.Options{
+		Addr: "localhost:6379",
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			if down.Load() {
+				return nil, errors.New("connection refused")
+			}
+			return new(net.Dialer).DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func TestRedisCacheRetry(t *testing.T) {
+	down, dials := new(atomic.Bool), new(atomic.Int32)
+	down.Store(true)
+	c := cache.NewRedisCache("test", flakyRedis(down, dials), cache.RedisRetry(2, time.Millisecond, 5*time.Millisecond))
+
+	if _, err := c.Get("retry"); err == nil {
+		t.Fatal("get must fail while redis is down")
+	}
+
+	if n := dials.Load(); n != 3 {
+		t.Fatalf("get must retried 2 times, get %d tries", n)
+	}
+
+	down.Store(false)
+	if err := c.Put("retry", "ok", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("retry"); err != nil || v != "ok" {
+		t.Fatalf("invalid value %v %v", v, err)
+	}
+	c.Forget("retry")
+	// redis client retries disabled, retries not stacked
+	addr, accepted := fakeRedis(t, true)
+	dropped := cache.NewRedisCache("test", redis.Options{Addr: addr}, cache.RedisRetry(2, time.Millisecond, 5*time.Millisecond))
+	if _, err := dropped.Get("retry"); err == nil {
+		t.Fatal("get must fail on dropped connection")
+	} else if n := accepted.Load(); n != 3 {
+		t.Fatalf("get must retried 2 times, get %d tries", n)
+	}
+}
+
+// hangingRedis listen on random port accepting connections and never replying
+func hangingRedis(t *testing.T) string {
+	addr, _ := fakeRedis(t, false)
+	return addr
+}
+
+// fakeRedis listen on random port accepting and counting connections, connections
+// closed after first read if drop is true and left open without reply otherwise
+func fakeRedis(t *testing.T, drop bool) (string, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := new(atomic.Int32)
+	conns := make(chan net.Conn, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			if drop {
+				conn.Read(make([]byte, 1024))
+				conn.Close()
+				continue
+			}
+			conns <- conn
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+		close(conns)
+		for conn := range conns {
+			conn.Close()
+		}
+	})
+	return listener.Addr().String(), accepted
+}
+
+func TestRedisCacheTimeout(t *testing.T) {
+	addr := hangingRedis(t)
+	c := cache.NewRedisCache("test", redis.Options{Addr: addr, MaxRetries: -1}, cache.RedisTimeout(100*time.Millisecond))
+	start := time.Now()
+	if err := c.Put("timeout", "value", time.Second); err == nil {
+		t.Fatal("operation must timed out")
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("operation must timed out after 100ms, get %s", elapsed)
+	}
+
+	// retries not stacked on redis client retries
+	retried := cache.NewRedisCache("test", redis.Options{Addr: addr}, cache.RedisTimeout(100*time.Millisecond), cache.RedisRetry(1, time.Millisecond, time.Millisecond))
+	start = time.Now()
+	if _, err := retried.Get("timeout"); err == nil {
+		t.Fatal("operation must timed out")
+	} else if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("operation must tried 2 times, get %s", elapsed)
+	}
+}
+
+func TestRedisCacheCircuitBreaker(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	down, dials := new(atomic.Bool), new(atomic.Int32)
+	mutex, changes := new(sync.Mutex), []string{}
+	onChange := func(from, to cache.CircuitState) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		changes = append(changes, from.String()+">"+to.String())
+	}
+
+	// fail fast
+	down.Store(true)
+	failFast := cache.NewRedisCache("test", flakyRedis(down, dials), cache.RedisCircuitBreaker(1, time.Minute, nil))
+	failFast.Get("breaker")
+	dials.Store(0)
+	if _, err := failFast.Get("breaker"); err == nil || !strings.Contains(err.Error(), "circuit breaker is open") {
+		t.Fatalf("open circuit must fail fast %v", err)
+	} else if dials.Load() != 0 {
+		t.Fatal("open circuit must not call redis")
+	}
+
+	// fallback
+	c := cache.NewRedisCache(
+		"test",
+		flakyRedis(down, dials),
+		cache.RedisCircuitBreaker(2, 200*time.Millisecond, onChange),
+		cache.RedisFallback(fileCache()),
+	)
+
+	if err := c.Put("breaker", "redis", time.Minute); err == nil {
+		t.Fatal("put must fail before circuit opened")
+	}
+
+	if err := c.Put("breaker", "fallback", time.Minute); err != nil {
+		t.Fatalf("fallback must used after circuit opened %v", err)
+	}
+
+	if v, err := c.Get("breaker"); err != nil || v != "fallback" {
+		t.Fatalf("invalid fallback value %v %v", v, err)
+	}
+
+	// recovery
+	down.Store(false)
+	time.Sleep(250 * time.Millisecond)
+	if err := c.Put("breaker", "redis", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Get("breaker"); err != nil || v != "redis" {
+		t.Fatalf("invalid value after recovery %v %v", v, err)
+	}
+	c.Forget("breaker")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if fmt.Sprint(changes) != "[closed>open open>half-open half-open>closed]" {
+		t.Fatalf("invalid state changes %v", changes)
+	}
+}
+
+func TestRedisCacheNativeDriverProtections(t *testing.T) {
+	down, dials := new(atomic.Bool), new(atomic.Int32)
+	down.Store(true)
+	c := cache.NewRedisCache("test", flakyRedis(down, dials), cache.RedisCircuitBreaker(1, time.Minute, nil))
+	limiter, err := cache.NewRateLimiter("test-native-breaker", 5, time.Minute, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := limiter.Attempt(); err == nil {
+		t.Fatal("attempt must fail while redis is down")
+	}
+
+	dials.Store(0)
+	start := time.Now()
+	if _, _, _, err := limiter.Attempt(); err == nil || !strings.Contains(err.Error(), "circuit breaker is open") {
+		t.Fatalf("open circuit must fail limiter fast %v", err)
+	} else if dials.Load() != 0 || time.Since(start) > 50*time.Millisecond {
+		t.Fatal("open circuit must not call redis")
+	}
+
+	// timeout applied to native scripts
+	slow := cache.NewRedisCache("test", redis.Options{Addr: hangingRedis(t), MaxRetries: -1}, cache.RedisTimeout(100*time.Millisecond))
+	lock, err := cache.NewLock("test-native-timeout", time.Minute, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start = time.Now()
+	if _, err := lock.TryLock(); err == nil {
+		t.Fatal("lock must fail on timeout")
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("lock must timed out after 100ms, get %s", elapsed)
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState circuit breaker state
+type CircuitState int
+
+const (
+	// CircuitClosed calls allowed
+	CircuitClosed CircuitState = iota
+	// CircuitOpen calls rejected until cooldown passed
+	CircuitOpen
+	// CircuitHalfOpen one trial call allowed, success close and failure re-open circuit
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// cBreaker circuit breaker opened after threshold consecutive failures
+type cBreaker struct {
+	threshold uint32
+	cooldown  time.Duration
+	onChange  func(from, to CircuitState)
+	mutex     *sync.Mutex
+	state     CircuitState
+	failures  uint32
+	openedAt  time.Time
+	probing   bool
+}
+
+func (cb *cBreaker) init(threshold uint32, cooldown time.Duration, onChange func(from, to CircuitState)) {
+	cb.threshold = max(1, threshold)
+	cb.cooldown = cooldown
+	cb.onChange = onChange
+	cb.mutex = new(sync.Mutex)
+	cb.state = CircuitClosed
+}
+
+// transit change state, return state change notifier to call after unlock
+func (cb *cBreaker) transit(to CircuitState) func() {
+	from := cb.state
+	cb.state = to
+	cb.probing = false
+	if to == CircuitOpen {
+		cb.openedAt = time.Now()
+	} else if to == CircuitClosed {
+		cb.failures = 0
+	}
+
+	if cb.onChange == nil || from == to {
+		return func() {}
+	}
+	return func() { cb.onChange(from, to) }
+}
+
+// rejects check if call rejected without starting trial call
+func (cb *cBreaker) rejects() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		return time.Since(cb.openedAt) < cb.cooldown
+	case CircuitHalfOpen:
+		return cb.probing
+	default:
+		return false
+	}
+}
+
+// allow check if call allowed, first call after cooldown start half open trial
+func (cb *cBreaker) allow() bool {
+	notify := func() {}
+	defer func() { notify() }()
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.cooldown {
+		notify = cb.transit(CircuitHalfOpen)
+	}
+
+	switch cb.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// report report allowed call result
+func (cb *cBreaker) report(failed bool) {
+	notify := func() {}
+	defer func() { notify() }()
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	switch {
+	case cb.state == CircuitHalfOpen && failed:
+		notify = cb.transit(CircuitOpen)
+	case cb.state == CircuitHalfOpen:
+		notify = cb.transit(CircuitClosed)
+	case failed:
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			notify = cb.transit(CircuitOpen)
+		}
+	default:
+		cb.failures = 0
+	}
+}
@@ -0,0 +1,18 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// process wide striped mutex used by drivers for read-modify-write of cache items
+var keyMutexes [256]sync.Mutex
+
+// lockKey lock key mutex and return unlock function
+func lockKey(key string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	mu := &keyMutexes[h.Sum32()%uint32(len(keyMutexes))]
+	mu.Lock()
+	return mu.Unlock
+}
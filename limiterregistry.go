@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomig/utils"
+)
+
+// RateLimitPolicy named rate limiter policy
+type RateLimitPolicy struct {
+	// Name policy name
+	Name string
+	// Algorithm rate limiter algorithm
+	Algorithm RateLimiterAlgorithm
+	// MaxAttempts max attempts allowed in ttl
+	MaxAttempts uint32
+	// TTL rate limiter window
+	TTL time.Duration
+}
+
+// LimiterRegistry interface for managing rate limiters of named policies per identity (ip, user, ...)
+type LimiterRegistry interface {
+	// Register add or replace policies, policy name must not be empty or contains :
+	Register(policies ...RateLimitPolicy) error
+	// Composite register composite policy, attempt allowed only if all policies allow it.
+	// composite policies must use FixedWindow policies, attempts evaluated by one
+	// atomic script on redis driver and under process lock on other drivers
+	Composite(name string, policies ...string) error
+	// Limiter get rate limiter of policy for identity
+	Limiter(policy, identity string) (RateLimiter, error)
+	// Attempt check and hit policy for identities, composite policy get one identity
+	// per policy in registration order. return allowed, min retries left and max
+	// time until next attempt allowed if not allowed
+	Attempt(policy string, identities ...string) (bool, uint32, time.Duration, error)
+}
+
+// ParseRateLimitPolicy parse fixed window policy from "name: max per window" spec.
+// window can be duration (10m) or optionally counted unit (hour, 2 days)
+func ParseRateLimitPolicy(spec string) (RateLimitPolicy, error) {
+	err := func() error {
+		return utils.TaggedError([]string{"RateLimitPolicy"}, "invalid policy %q", spec)
+	}
+
+	name, rule, ok := strings.Cut(spec, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return RateLimitPolicy{}, err()
+	}
+
+	fields := strings.Fields(strings.ToLower(rule))
+	if len(fields) < 3 || len(fields) > 4 || fields[1] != "per" {
+		return RateLimitPolicy{}, err()
+	}
+
+	max, e := strconv.ParseUint(fields[0], 10, 32)
+	if e != nil {
+		return RateLimitPolicy{}, err()
+	}
+
+	var ttl time.Duration
+	if len(fields) == 3 {
+		if d, e := time.ParseDuration(fields[2]); e == nil {
+			ttl = d
+		} else {
+			ttl = policyUnit(fields[2])
+		}
+	} else if count, e := strconv.ParseUint(fields[2], 10, 32); e == nil {
+		ttl = time.Duration(count) * policyUnit(fields[3])
+	}
+
+	if ttl <= 0 {
+		return RateLimitPolicy{}, err()
+	}
+
+	return RateLimitPolicy{
+		Name:        strings.TrimSpace(name),
+		Algorithm:   FixedWindow,
+		MaxAttempts: uint32(max),
+		TTL:         ttl,
+	}, nil
+}
+
+// policyUnit parse policy window unit, return zero for invalid unit
+func policyUnit(unit string) time.Duration {
+	switch strings.TrimSuffix(unit, "s") {
+	case "second", "sec":
+		return time.Second
+	case "minute", "min":
+		return time.Minute
+	case "hour":
+		return time.Hour
+	case "day":
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// check all fixed window limiters and consume one attempt from each if all allowed
+var lrAttemptScript = redis.NewScript(`
+local lefts, wait, denied = {}, 0, false
+for i = 1, #KEYS do
+	local max = tonumber(ARGV[2 * i - 1])
+	local v = tonumber(redis.call("GET", KEYS[i])) or max
+	if v <= 0 then
+		denied = true
+		local ttl = redis.call("PTTL", KEYS[i])
+		if ttl < 0 then
+			ttl = tonumber(ARGV[2 * i])
+		end
+		wait = math.max(wait, ttl)
+	end
+	lefts[i] = v
+end
+if denied then
+	return {0, 0, wait}
+end
+local left = -1
+for i = 1, #KEYS do
+	if redis.call("EXISTS", KEYS[i]) == 0 then
+		redis.call("SET", KEYS[i], lefts[i] - 1, "PX", ARGV[2 * i])
+	else
+		redis.call("DECRBY", KEYS[i], 1)
+	end
+	if left < 0 or lefts[i] - 1 < left then
+		left = lefts[i] - 1
+	end
+end
+return {1, left, 0}
+`)
+
+type lRegistry struct {
+	cache      Cache
+	mutex      *sync.RWMutex
+	attempts   *sync.Mutex
+	policies   map[string]RateLimitPolicy
+	composites map[string][]string
+}
+
+func (lr lRegistry) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"LimiterRegistry"}, pattern, params...)
+}
+
+func (lr *lRegistry) init(cache Cache, policies ...RateLimitPolicy) error {
+	lr.cache = cache
+	lr.mutex = new(sync.RWMutex)
+	lr.attempts = new(sync.Mutex)
+	lr.policies = make(map[string]RateLimitPolicy)
+	lr.composites = make(map[string][]string)
+	return lr.Register(policies...)
+}
+
+// keyOf get limiter key of policy identity, policy names can't contain : so
+// policy and identity pairs never share key
+func (lr lRegistry) keyOf(policy, identity string) string {
+	return utils.ConcatStr(":", "limiter-"+policy, identity)
+}
+
+func (lr lRegistry) validate(name string) error {
+	if name == "" || strings.Contains(name, ":") {
+		return lr.err("invalid policy name %q", name)
+	}
+	return nil
+}
+
+// parts get policies of simple or composite policy
+func (lr lRegistry) parts(name string) ([]RateLimitPolicy, error) {
+	lr.mutex.RLock()
+	defer lr.mutex.RUnlock()
+
+	if p, ok := lr.policies[name]; ok {
+		return []RateLimitPolicy{p}, nil
+	}
+
+	names, ok := lr.composites[name]
+	if !ok {
+		return nil, lr.err("policy %s not exists", name)
+	}
+
+	res := make([]RateLimitPolicy, 0, len(names))
+	for _, n := range names {
+		if p, ok := lr.policies[n]; !ok {
+			return nil, lr.err("policy %s of %s not exists", n, name)
+		} else if p.Algorithm != FixedWindow {
+			// policy replaced after composite registered
+			return nil, lr.err("policy %s of %s is not fixed window", n, name)
+		} else {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func (lr lRegistry) Register(policies ...RateLimitPolicy) error {
+	for _, p := range policies {
+		if err := lr.validate(p.Name); err != nil {
+			return err
+		}
+	}
+
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	for _, p := range policies {
+		lr.policies[p.Name] = p
+	}
+	return nil
+}
+
+func (lr lRegistry) Composite(name string, policies ...string) error {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	if err := lr.validate(name); err != nil {
+		return err
+	}
+
+	if len(policies) == 0 {
+		return lr.err("composite policy %s has no policy", name)
+	}
+
+	for _, p := range policies {
+		if policy, ok := lr.policies[p]; !ok {
+			return lr.err("policy %s not exists", p)
+		} else if policy.Algorithm != FixedWindow {
+			return lr.err("composite policy %s must use fixed window policies, %s is not fixed window", name, p)
+		}
+	}
+
+	lr.composites[name] = policies
+	return nil
+}
+
+func (lr lRegistry) Limiter(policy, identity string) (RateLimiter, error) {
+	lr.mutex.RLock()
+	p, ok := lr.policies[policy]
+	lr.mutex.RUnlock()
+	if !ok {
+		return nil, lr.err("policy %s not exists", policy)
+	}
+
+	return NewRateLimiterWith(p.Algorithm, lr.keyOf(p.Name, identity), p.MaxAttempts, p.TTL, lr.cache)
+}
+
+func (lr lRegistry) Attempt(policy string, identities ...string) (bool, uint32, time.Duration, error) {
+	parts, err := lr.parts(policy)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	if len(parts) != len(identities) {
+		return false, 0, 0, lr.err("policy %s need %d identities", policy, len(parts))
+	}
+
+	limiters := make([]RateLimiter, 0, len(parts))
+	for i, p := range parts {
+		limiter, err := lr.Limiter(p.Name, identities[i])
+		if err != nil {
+			return false, 0, 0, err
+		}
+		limiters = append(limiters, limiter)
+	}
+
+	if len(limiters) == 1 {
+		return limiters[0].Attempt()
+	}
+
+	// composite policies evaluated by one script on redis driver
+	if client, _ := redisOf(lr.cache, ""); client != nil {
+		keys := make([]string, 0, len(parts))
+		args := make([]any, 0, 2*len(parts))
+		for i, p := range parts {
+			_, key := redisOf(lr.cache, lr.keyOf(p.Name, identities[i]))
+			keys = append(keys, key)
+			args = append(args, p.MaxAttempts, p.TTL.Milliseconds())
+		}
+
+		res, err := client.run(lrAttemptScript, keys, args...).Int64Slice()
+		if err != nil {
+			return false, 0, 0, lr.err(err.Error())
+		}
+		return res[0] == 1, uint32(max(0, res[1])), time.Duration(res[2]) * time.Millisecond, nil
+	}
+
+	// other drivers evaluated under process lock, not atomic across processes
+	lr.attempts.Lock()
+	defer lr.attempts.Unlock()
+
+	var wait time.Duration
+	denied := false
+	for _, limiter := range limiters {
+		if locked, err := limiter.MustLock(); err != nil {
+			return false, 0, 0, err
+		} else if locked {
+			denied = true
+			if ttl, err := limiter.AvailableIn(); err != nil {
+				return false, 0, 0, err
+			} else {
+				wait = max(wait, ttl)
+			}
+		}
+	}
+
+	if denied {
+		return false, 0, wait, nil
+	}
+
+	left := uint32(0)
+	for i, limiter := range limiters {
+		if err := limiter.Hit(); err != nil {
+			return false, 0, 0, err
+		}
+
+		if l, err := limiter.RetriesLeft(); err != nil {
+			return false, 0, 0, err
+		} else if i == 0 || l < left {
+			left = l
+		}
+	}
+	return true, left, 0, nil
+}
@@ -0,0 +1,161 @@
+package cache_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	tests := map[string]time.Duration{
+		"login: 5 per 10m":      10 * time.Minute,
+		"api: 1000 per hour":    time.Hour,
+		"sms: 3 per 2 days":     48 * time.Hour,
+		"search: 10 per second": time.Second,
+	}
+
+	for spec, ttl := range tests {
+		p, err := cache.ParseRateLimitPolicy(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.TTL != ttl {
+			t.Fatalf("%s: want %s ttl, get %s", spec, ttl, p.TTL)
+		}
+	}
+
+	for _, spec := range []string{"5 per 10m", "login: 5 in 10m", "login: x per hour", "login: 5 per fortnight"} {
+		if _, err := cache.ParseRateLimitPolicy(spec); err == nil {
+			t.Fatalf("%s: invalid spec parsed", spec)
+		}
+	}
+}
+
+func TestLimiterRegistryComposite(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		ip, _ := cache.ParseRateLimitPolicy("login-ip: 3 per 1m")
+		account, _ := cache.ParseRateLimitPolicy("login-account: 2 per 1m")
+		registry, err := cache.NewLimiterRegistry(c, ip, account)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := registry.Composite("login", "login-ip", "login-account"); err != nil {
+			t.Fatal(err)
+		}
+
+		for policy, identity := range map[string]string{"login-ip": "1.1.1.1", "login-account": "john"} {
+			if l, err := registry.Limiter(policy, identity); err != nil {
+				t.Fatal(err)
+			} else if err := l.Clear(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if l, err := registry.Limiter("login-account", "jack"); err != nil {
+			t.Fatal(err)
+		} else if err := l.Clear(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if ok, _, _, err := registry.Attempt("login", "1.1.1.1", "john"); err != nil {
+				t.Fatal(err)
+			} else if !ok {
+				t.Fatalf("%s: attempt %d must allowed", name, i)
+			}
+		}
+
+		// account limit reached, ip limit must not consumed
+		if ok, _, wait, err := registry.Attempt("login", "1.1.1.1", "john"); err != nil {
+			t.Fatal(err)
+		} else if ok || wait <= 0 {
+			t.Fatalf("%s: account limit must deny attempt", name)
+		}
+
+		if ok, left, _, err := registry.Attempt("login", "1.1.1.1", "jack"); err != nil {
+			t.Fatal(err)
+		} else if !ok || left != 0 {
+			t.Fatalf("%s: want allowed with 0 ip retries left, get %v %d", name, ok, left)
+		}
+
+		if ok, _, _, err := registry.Attempt("login", "1.1.1.1", "jack"); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatalf("%s: ip limit must deny attempt", name)
+		}
+
+		if _, _, _, err := registry.Attempt("login", "1.1.1.1"); err == nil {
+			t.Fatalf("%s: missing identity must fail", name)
+		}
+	}
+}
+
+func TestLimiterRegistryCompositeAlgorithm(t *testing.T) {
+	ip, _ := cache.ParseRateLimitPolicy("login-ip: 3 per 1m")
+	account := cache.RateLimitPolicy{Name: "login-account", Algorithm: cache.TokenBucket, MaxAttempts: 2, TTL: time.Minute}
+	registry, err := cache.NewLimiterRegistry(redisCache(), ip, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Composite("login", "login-ip", "login-account"); err == nil {
+		t.Fatal("composite of non fixed window policy must fail")
+	}
+
+	account.Algorithm = cache.FixedWindow
+	if err := registry.Register(account); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Composite("login", "login-ip", "login-account"); err != nil {
+		t.Fatal(err)
+	}
+
+	// policy replaced after composite registered
+	account.Algorithm = cache.LeakyBucket
+	if err := registry.Register(account); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := registry.Attempt("login", "1.1.1.1", "john"); err == nil {
+		t.Fatal("composite with replaced non fixed window policy must fail")
+	}
+}
+
+func TestLimiterRegistryKeys(t *testing.T) {
+	registry, err := cache.NewLimiterRegistry(
+		redisCache(),
+		cache.RateLimitPolicy{Name: "a-b", Algorithm: cache.FixedWindow, MaxAttempts: 1, TTL: time.Minute},
+		cache.RateLimitPolicy{Name: "a", Algorithm: cache.FixedWindow, MaxAttempts: 1, TTL: time.Minute},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range [][2]string{{"a-b", "c"}, {"a", "b-c"}} {
+		if limiter, err := registry.Limiter(l[0], l[1]); err != nil {
+			t.Fatal(err)
+		} else if err := limiter.Clear(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if allowed, _, _, err := registry.Attempt("a-b", "c"); err != nil || !allowed {
+		t.Fatalf("first attempt must allowed %v", err)
+	}
+
+	if allowed, _, _, err := registry.Attempt("a", "b-c"); err != nil || !allowed {
+		t.Fatalf("other policy identity pair must not share counter %v", err)
+	}
+
+	if err := registry.Register(cache.RateLimitPolicy{Name: "a:b"}); err == nil {
+		t.Fatal("policy name with : must fail")
+	}
+
+	if _, err := cache.NewLimiterRegistry(redisCache(), cache.RateLimitPolicy{}); err == nil {
+		t.Fatal("empty policy name must fail")
+	}
+}
@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Lock interface for distributed lock. each lock instance is one owner
+// identified by random token, lock released automatically after ttl
+type Lock interface {
+	// TryLock acquire lock without waiting, return false if lock held
+	TryLock() (bool, error)
+	// Lock wait until lock acquired or context done, retry with exponential backoff
+	Lock(ctx context.Context) error
+	// Unlock release lock if still owned, return false if lock not owned
+	Unlock() (bool, error)
+	// Extend set lock ttl if still owned, return false if lock not owned
+	Extend(ttl time.Duration) (bool, error)
+	// Watchdog renew lock every third of ttl until unlocked or context done.
+	// returned channel get error if renew failed or lock lost and closed
+	// after watchdog stopped
+	Watchdog(ctx context.Context) <-chan error
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// delete lock if owned by token
+var lockUnlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// set lock ttl if owned by token
+var lockExtendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// lDriver distributed lock. lock acquired by SET NX PX on redis driver and guarded
+// compare and swap on file driver (safe across processes sharing cache dir), other
+// drivers not supported.
+type lDriver struct {
+	key    string
+	token  string
+	ttl    time.Duration
+	client *rCache
+	rKey   string
+	file   *fCache
+	fKey   string
+	mutex  *sync.Mutex
+	stop   context.CancelFunc
+}
+
+func (l *lDriver) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"Lock", l.key}, pattern, params...)
+}
+
+func (l *lDriver) init(key string, ttl time.Duration, cache Cache) error {
+	if ttl < time.Millisecond {
+		return l.err("invalid ttl %s", ttl)
+	}
+
+	token, err := utils.RandomStringFromCharset(20, "0123456789abcdefghijklmnopqrstuvwxyz")
+	if err != nil {
+		return l.err(err.Error())
+	}
+
+	l.key = key
+	l.token = token
+	l.ttl = ttl
+	l.client, l.rKey = redisOf(cache, key)
+	l.file, l.fKey = fileOf(cache, key)
+	l.mutex = new(sync.Mutex)
+	if l.client == nil && l.file == nil {
+		return l.err("lock need redis or file cache driver, chained cache must not change values")
+	}
+	return nil
+}
+
+func (l *lDriver) TryLock() (bool, error) {
+	if l.client != nil {
+		var ok bool
+		err := l.client.do(false, func(ctx context.Context, client *redis.Client) (err error) {
+			ok, err = client.SetNX(ctx, l.rKey, l.token, l.ttl).Result()
+			return err
+		})
+		if err != nil {
+			return false, l.err(err.Error())
+		}
+		return ok, nil
+	}
+
+	return l.file.create(l.fKey, record{
+		TTL:  time.Now().UTC().Add(l.ttl),
+		Data: l.token,
+	})
+}
+
+func (l *lDriver) Lock(ctx context.Context) error {
+	wait := 10 * time.Millisecond
+	for {
+		if ok, err := l.TryLock(); err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+
+		// jitter prevent waiting owners retry together
+		timer := time.NewTimer(wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		wait = min(2*wait, 500*time.Millisecond)
+	}
+}
+
+func (l *lDriver) Unlock() (bool, error) {
+	l.mutex.Lock()
+	if l.stop != nil {
+		l.stop()
+		l.stop = nil
+	}
+	l.mutex.Unlock()
+
+	if l.client != nil {
+		res, err := l.client.run(lockUnlockScript, []string{l.rKey}, l.token).Int()
+		if err != nil {
+			return false, l.err(err.Error())
+		}
+		return res == 1, nil
+	}
+
+	return l.file.swap(l.fKey, l.token, nil)
+}
+
+func (l *lDriver) Extend(ttl time.Duration) (bool, error) {
+	if ttl < time.Millisecond {
+		return false, l.err("invalid ttl %s", ttl)
+	}
+
+	if l.client != nil {
+		res, err := l.client.run(lockExtendScript, []string{l.rKey}, l.token, ttl.Milliseconds()).Int()
+		if err != nil {
+			return false, l.err(err.Error())
+		}
+		return res == 1, nil
+	}
+
+	return l.file.swap(l.fKey, l.token, &record{
+		TTL:  time.Now().UTC().Add(ttl),
+		Data: l.token,
+	})
+}
+
+func (l *lDriver) Watchdog(ctx context.Context) <-chan error {
+	ctx, cancel := context.WithCancel(ctx)
+	l.mutex.Lock()
+	if l.stop != nil {
+		l.stop()
+	}
+	l.stop = cancel
+	l.mutex.Unlock()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer cancel()
+
+		ticker := time.NewTicker(max(l.ttl/3, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if ok, err := l.Extend(l.ttl); ctx.Err() != nil {
+				return
+			} else if err != nil {
+				errs <- err
+				return
+			} else if !ok {
+				errs <- l.err("lock lost")
+				return
+			}
+		}
+	}()
+	return errs
+}
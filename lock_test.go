@@ -0,0 +1,228 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestLock(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-lock"); err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := cache.NewLock("test-lock", 300*time.Millisecond, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := cache.NewLock("test-lock", 300*time.Millisecond, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := first.TryLock(); err != nil || !ok {
+			t.Fatalf("%s: first lock failed %v", name, err)
+		}
+
+		if ok, err := second.TryLock(); err != nil || ok {
+			t.Fatalf("%s: second lock must fail %v", name, err)
+		}
+
+		if ok, err := second.Unlock(); err != nil || ok {
+			t.Fatalf("%s: not owned lock must not unlock %v", name, err)
+		}
+
+		if ok, err := second.Extend(time.Second); err != nil || ok {
+			t.Fatalf("%s: not owned lock must not extend %v", name, err)
+		}
+
+		// watchdog keep lock after ttl
+		errs := first.Watchdog(context.Background())
+		time.Sleep(500 * time.Millisecond)
+		if ok, err := second.TryLock(); err != nil || ok {
+			t.Fatalf("%s: watchdog must renew lock %v", name, err)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			first.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := second.Lock(ctx); err != nil {
+			t.Fatalf("%s: lock after unlock failed %v", name, err)
+		}
+		cancel()
+
+		if err, ok := <-errs; ok {
+			t.Fatalf("%s: watchdog must stop on unlock, get %v", name, err)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		if err := first.Lock(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("%s: lock must timeout, get %v", name, err)
+		}
+		cancel()
+
+		// lock released after ttl
+		time.Sleep(350 * time.Millisecond)
+		if ok, err := first.TryLock(); err != nil || !ok {
+			t.Fatalf("%s: lock must expire %v", name, err)
+		}
+
+		if ok, err := first.Unlock(); err != nil || !ok {
+			t.Fatalf("%s: unlock failed %v", name, err)
+		}
+	}
+}
+
+func TestLockConcurrency(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-lock-concurrency"); err != nil {
+			t.Fatal(err)
+		}
+
+		var acquired int32
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				l, err := cache.NewLock("test-lock-concurrency", time.Second, c)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if ok, err := l.TryLock(); err != nil {
+					t.Error(err)
+				} else if ok {
+					atomic.AddInt32(&acquired, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if acquired != 1 {
+			t.Fatalf("%s: want 1 owner, get %d", name, acquired)
+		}
+	}
+}
+
+func TestLockExpiredOwner(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-lock-expired"); err != nil {
+			t.Fatal(err)
+		}
+
+		first, _ := cache.NewLock("test-lock-expired", time.Second, c)
+		second, _ := cache.NewLock("test-lock-expired", time.Minute, c)
+		if ok, err := first.TryLock(); err != nil || !ok {
+			t.Fatalf("%s: first lock failed %v", name, err)
+		}
+
+		time.Sleep(1100 * time.Millisecond)
+		if ok, err := second.TryLock(); err != nil || !ok {
+			t.Fatalf("%s: expired lock must acquired %v", name, err)
+		}
+
+		if ok, err := first.Unlock(); err != nil || ok {
+			t.Fatalf("%s: expired owner must not unlock new owner %v", name, err)
+		}
+
+		if ok, err := first.Extend(time.Minute); err != nil || ok {
+			t.Fatalf("%s: expired owner must not extend new owner %v", name, err)
+		}
+
+		if ok, err := second.Unlock(); err != nil || !ok {
+			t.Fatalf("%s: new owner unlock failed %v", name, err)
+		}
+	}
+}
+
+func TestLockUnsupportedCache(t *testing.T) {
+	opaque := cache.Chain(redisCache(), func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+		if call.Op == "map_key" {
+			return nil, nil
+		}
+		return next(call)
+	})
+
+	if _, err := cache.NewLock("test-lock-unsupported", time.Minute, opaque); err == nil {
+		t.Fatal("lock on cache without native driver must fail")
+	}
+}
+
+// lockProcess acquire file lock repeatedly and fail if other process hold marker
+// file while lock is held. half of locks left to expire for racing on expired
+// lock. run as child process of TestLockProcesses
+func lockProcess() error {
+	l, err := cache.NewLock("test-lock-processes", 50*time.Millisecond, fileCache())
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := l.Lock(context.Background()); err != nil {
+			return err
+		}
+
+		marker, err := os.OpenFile("./caches/lock-marker", os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return errors.New("lock held by two processes")
+		}
+		marker.Close()
+		time.Sleep(5 * time.Millisecond)
+		os.Remove("./caches/lock-marker")
+
+		if i%2 == 1 {
+			continue
+		} else if ok, err := l.Unlock(); err != nil {
+			return err
+		} else if !ok {
+			return errors.New("lock lost")
+		}
+	}
+	return nil
+}
+
+func TestLockProcesses(t *testing.T) {
+	if os.Getenv("LOCK_PROCESS") == "1" {
+		if err := lockProcess(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	defer os.RemoveAll("./caches")
+	fileCache().Forget("test-lock-processes")
+	os.MkdirAll("./caches", 0755)
+
+	processes := make([]*exec.Cmd, 0, 4)
+	for i := 0; i < 4; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLockProcesses$")
+		cmd.Env = append(os.Environ(), "LOCK_PROCESS=1")
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		processes = append(processes, cmd)
+	}
+
+	for _, cmd := range processes {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("lock process failed %v", err)
+		}
+	}
+}
@@ -1,18 +1,26 @@
 package cache
 
 import (
+	"context"
 	"os"
 	"path"
 	"time"
 
+	"github.com/gomig/utils"
 	"github.com/redis/go-redis/v9"
 )
 
 // NewRedisCache create a new redis cache manager instance
-func NewRedisCache(prefix string, opt redis.Options) Cache {
+func NewRedisCache(prefix string, opt redis.Options, options ...RedisCacheOption) Cache {
 	rc := new(rCache)
-	rc.init(prefix, opt)
-	return rc
+	rc.init(prefix, opt, options...)
+	if rc.breaker == nil || rc.fallback == nil {
+		return rc
+	}
+
+	cc := new(cCache)
+	cc.init(rc, true, rc.fallbackMiddleware())
+	return cc
 }
 
 // NewFileCache create a new file cache manager instance
@@ -32,6 +40,87 @@ func NewRateLimiter(key string, maxAttempts uint32, ttl time.Duration, cache Cac
 	}
 }
 
+// NewRateLimiterWith create a new rate limiter using algorithm
+func NewRateLimiterWith(algorithm RateLimiterAlgorithm, key string, maxAttempts uint32, ttl time.Duration, cache Cache) (RateLimiter, error) {
+	switch algorithm {
+	case FixedWindow:
+		return NewRateLimiter(key, maxAttempts, ttl, cache)
+	case SlidingWindowLog:
+		limiter := new(slLimiter)
+		if err := limiter.init(key, maxAttempts, ttl, cache); err != nil {
+			return nil, err
+		} else {
+			return limiter, nil
+		}
+	case SlidingWindowCounter:
+		limiter := new(scLimiter)
+		if err := limiter.init(key, maxAttempts, ttl, cache); err != nil {
+			return nil, err
+		} else {
+			return limiter, nil
+		}
+	case TokenBucket:
+		limiter := new(tbLimiter)
+		if err := limiter.init(key, maxAttempts, ttl, cache); err != nil {
+			return nil, err
+		} else {
+			return limiter, nil
+		}
+	case LeakyBucket:
+		limiter := new(gLimiter)
+		if err := limiter.init(key, maxAttempts, ttl, cache); err != nil {
+			return nil, err
+		} else {
+			return limiter, nil
+		}
+	default:
+		return nil, utils.TaggedError([]string{"RateLimiter", key}, "unknown algorithm %d", algorithm)
+	}
+}
+
+// NewProgressiveRateLimiter create a new rate limiter with escalating lockout penalties
+// on top of limiter. strikes forgotten after decay time without new strike
+func NewProgressiveRateLimiter(key string, limiter RateLimiter, penalties []time.Duration, decay time.Duration, cache Cache) (ProgressiveRateLimiter, error) {
+	pl := new(pLimiter)
+	if err := pl.init(key, limiter, penalties, decay, cache); err != nil {
+		return nil, err
+	} else {
+		return pl, nil
+	}
+}
+
+// NewSemaphore create a new semaphore holder allowing limit concurrent holders,
+// held slot released automatically after lease ttl if not refreshed. cache must be
+// redis or file driver (or chain of them with key only middlewares)
+func NewSemaphore(key string, limit uint32, lease time.Duration, cache Cache) (Semaphore, error) {
+	s := new(sDriver)
+	if err := s.init(key, limit, lease, cache); err != nil {
+		return nil, err
+	} else {
+		return s, nil
+	}
+}
+
+// NewLock create a new distributed lock owner, lock released automatically after ttl.
+// cache must be redis or file driver (or chain of them with key only middlewares)
+func NewLock(key string, ttl time.Duration, cache Cache) (Lock, error) {
+	l := new(lDriver)
+	if err := l.init(key, ttl, cache); err != nil {
+		return nil, err
+	} else {
+		return l, nil
+	}
+}
+
+// NewLimiterRegistry create a new rate limiter registry
+func NewLimiterRegistry(cache Cache, policies ...RateLimitPolicy) (LimiterRegistry, error) {
+	lr := new(lRegistry)
+	if err := lr.init(cache, policies...); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
+
 // NewRedisQueue create a new redis queue instance
 func NewRedisQueue(name string, opt redis.Options) Queue {
 	rq := new(rQueue)
@@ -39,16 +128,120 @@ func NewRedisQueue(name string, opt redis.Options) Queue {
 	return rq
 }
 
+// NewRedisStreamQueue create a new redis stream queue instance for consumer of group
+func NewRedisStreamQueue(name, group, consumer string, opt redis.Options) (StreamQueue, error) {
+	rq := new(rStreamQueue)
+	if err := rq.init(name, group, consumer, opt); err != nil {
+		return nil, err
+	} else {
+		return rq, nil
+	}
+}
+
 // NewVerificationCode create a new verification code manager instance
-func NewVerificationCode(key string, ttl time.Duration, cache Cache) (VerificationCode, error) {
+func NewVerificationCode(key string, ttl time.Duration, cache Cache, options ...VerificationCodeOption) (VerificationCode, error) {
 	vc := new(vcDriver)
-	if err := vc.init(key, ttl, cache); err != nil {
+	if err := vc.init(key, ttl, cache, options...); err != nil {
 		return nil, err
 	} else {
 		return vc, nil
 	}
 }
 
+// NewOneTimeToken create a new one time token manager, tokens expired after ttl
+func NewOneTimeToken(name string, ttl time.Duration, cache Cache) (OneTimeToken, error) {
+	ot := new(ottDriver)
+	if err := ot.init(name, ttl, cache); err != nil {
+		return nil, err
+	} else {
+		return ot, nil
+	}
+}
+
+// NewTOTP create a new time based one time password manager (RFC 6238)
+func NewTOTP(key string, cache Cache, options ...OTPOption) (OTP, error) {
+	o := new(otpDriver)
+	if err := o.init(key, true, cache, options...); err != nil {
+		return nil, err
+	} else {
+		return o, nil
+	}
+}
+
+// NewHOTP create a new counter based one time password manager (RFC 4226)
+func NewHOTP(key string, cache Cache, options ...OTPOption) (OTP, error) {
+	o := new(otpDriver)
+	if err := o.init(key, false, cache, options...); err != nil {
+		return nil, err
+	} else {
+		return o, nil
+	}
+}
+
+// NewMetrics create a new in memory metrics registry
+func NewMetrics() Metrics {
+	mr := new(mRegistry)
+	mr.init()
+	return mr
+}
+
+// NewMetricsCache wrap cache to record operation metrics with driver label
+func NewMetricsCache(cache Cache, driver string, metrics Metrics) Cache {
+	cc := new(cCache)
+	cc.init(cache, true, MetricsMiddleware(driver, metrics))
+	return cc
+}
+
+// NewMetricsQueue wrap queue to record operation metrics with driver label,
+// wrapped stream queues still implement StreamQueue
+func NewMetricsQueue(queue Queue, driver string, metrics Metrics) Queue {
+	if stream, ok := queue.(StreamQueue); ok {
+		return NewMetricsStreamQueue(stream, driver, metrics)
+	}
+	return &mQueue{queue: queue, driver: driver, metrics: metrics}
+}
+
+// NewMetricsStreamQueue wrap stream queue to record operation metrics with driver label
+func NewMetricsStreamQueue(queue StreamQueue, driver string, metrics Metrics) StreamQueue {
+	return &mStreamQueue{
+		mQueue: mQueue{queue: queue, driver: driver, metrics: metrics},
+		stream: queue,
+	}
+}
+
+// NewMetricsRateLimiter wrap rate limiter to record operation metrics with driver label
+func NewMetricsRateLimiter(limiter RateLimiter, driver string, metrics Metrics) RateLimiter {
+	return &mLimiter{limiter: limiter, driver: driver, metrics: metrics}
+}
+
+// NewHookedCache wrap cache to call hooks around cache operations
+func NewHookedCache(cache Cache, hooks ...CacheHook) Cache {
+	cc := new(cCache)
+	cc.init(cache, true, HooksMiddleware(hooks...))
+	return cc
+}
+
+// Chain wrap cache with middlewares, first middleware is outermost. rate limiters
+// and other drivers keep native implementation on chains of key only, metrics and
+// hooks middlewares and use generic cache operations on other chains
+func Chain(cache Cache, middlewares ...CacheMiddleware) Cache {
+	cc := new(cCache)
+	cc.init(cache, false, middlewares...)
+	return cc
+}
+
+// WithContext bind context to chain and hooked cache operations, context passed
+// to middlewares and hooks of all wrapped chains (e.g. per request tracing spans).
+// other caches returned as is
+func WithContext(cache Cache, ctx context.Context) Cache {
+	if cc, ok := cache.(*cCache); ok {
+		bound := *cc
+		bound.ctx = ctx
+		return &bound
+	}
+	return cache
+}
+
 // CleanFileExpiration clean file cache expired records
 func CleanFileExpiration(dir string) error {
 	files, err := os.ReadDir("./")
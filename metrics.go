@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Metrics interface for collecting operation metrics of cache, queue and rate limiter
+type Metrics interface {
+	// Observe record operation result (ok, error, hit, miss, allowed, denied) and duration
+	// of component (cache, queue, ratelimiter) driver
+	Observe(component, driver, op, result string, duration time.Duration)
+	// Count get recorded operations count
+	Count(component, driver, op, result string) uint64
+	// WritePrometheus write metrics in prometheus text exposition format
+	WritePrometheus(w io.Writer) error
+	// Handler get http handler serving metrics in prometheus text exposition format
+	Handler() http.Handler
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricBuckets operation duration histogram buckets in seconds
+var metricBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricKey struct {
+	component string
+	driver    string
+	op        string
+	result    string
+}
+
+type metricHistogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// mRegistry in memory metrics registry. operations counted by result and
+// durations kept as histogram per operation.
+type mRegistry struct {
+	mutex      *sync.Mutex
+	counters   map[metricKey]uint64
+	histograms map[metricKey]*metricHistogram
+}
+
+func (mr *mRegistry) init() {
+	mr.mutex = new(sync.Mutex)
+	mr.counters = make(map[metricKey]uint64)
+	mr.histograms = make(map[metricKey]*metricHistogram)
+}
+
+func (mr mRegistry) Observe(component, driver, op, result string, duration time.Duration) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	mr.counters[metricKey{component, driver, op, result}]++
+
+	key := metricKey{component, driver, op, ""}
+	h, ok := mr.histograms[key]
+	if !ok {
+		h = &metricHistogram{buckets: make([]uint64, len(metricBuckets))}
+		mr.histograms[key] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, le := range metricBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (mr mRegistry) Count(component, driver, op, result string) uint64 {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+	return mr.counters[metricKey{component, driver, op, result}]
+}
+
+func (mr mRegistry) WritePrometheus(w io.Writer) error {
+	mr.mutex.Lock()
+	counters := make(map[metricKey]uint64, len(mr.counters))
+	for k, v := range mr.counters {
+		counters[k] = v
+	}
+
+	histograms := make(map[metricKey]metricHistogram, len(mr.histograms))
+	for k, v := range mr.histograms {
+		histograms[k] = metricHistogram{
+			buckets: append([]uint64(nil), v.buckets...),
+			sum:     v.sum,
+			count:   v.count,
+		}
+	}
+	mr.mutex.Unlock()
+
+	// group metrics by component
+	components := make(map[string][]metricKey)
+	for k := range counters {
+		components[k.component] = append(components[k.component], k)
+	}
+	for k := range histograms {
+		components[k.component] = append(components[k.component], k)
+	}
+
+	names := make([]string, 0, len(components))
+	for c := range components {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, c := range names {
+		keys := components[c]
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keys[i], keys[j]
+			if a.driver != b.driver {
+				return a.driver < b.driver
+			} else if a.op != b.op {
+				return a.op < b.op
+			}
+			return a.result < b.result
+		})
+
+		total := c + "_operations_total"
+		fmt.Fprintf(buf, "# HELP %s Total %s operations by driver, operation and result.\n", total, c)
+		fmt.Fprintf(buf, "# TYPE %s counter\n", total)
+		for _, k := range keys {
+			if v, ok := counters[k]; ok {
+				fmt.Fprintf(buf, "%s{%s} %d\n", total, metricLabels("driver", k.driver, "op", k.op, "result", k.result), v)
+			}
+		}
+
+		duration := c + "_operation_duration_seconds"
+		fmt.Fprintf(buf, "# HELP %s %s operations duration by driver and operation.\n", duration, c)
+		fmt.Fprintf(buf, "# TYPE %s histogram\n", duration)
+		for _, k := range keys {
+			h, ok := histograms[k]
+			if !ok {
+				continue
+			}
+
+			for i, le := range metricBuckets {
+				labels := metricLabels("driver", k.driver, "op", k.op, "le", strconv.FormatFloat(le, 'g', -1, 64))
+				fmt.Fprintf(buf, "%s_bucket{%s} %d\n", duration, labels, h.buckets[i])
+			}
+			fmt.Fprintf(buf, "%s_bucket{%s} %d\n", duration, metricLabels("driver", k.driver, "op", k.op, "le", "+Inf"), h.count)
+			fmt.Fprintf(buf, "%s_sum{%s} %s\n", duration, metricLabels("driver", k.driver, "op", k.op), strconv.FormatFloat(h.sum, 'g', -1, 64))
+			fmt.Fprintf(buf, "%s_count{%s} %d\n", duration, metricLabels("driver", k.driver, "op", k.op), h.count)
+		}
+	}
+	return buf.Flush()
+}
+
+func (mr mRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := mr.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// metricLabels format label name and value pairs with escaped values
+func metricLabels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+	return strings.Join(labels, ",")
+}
@@ -0,0 +1,128 @@
+package cache_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := cache.NewMetrics()
+	c := cache.NewMetricsCache(redisCache(), "redis", metrics)
+
+	if err := c.Put("test-metrics", "value", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("test-metrics"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("test-metrics-missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits, misses := metrics.Count("cache", "redis", "get", "hit"), metrics.Count("cache", "redis", "get", "miss"); hits != 1 || misses != 1 {
+		t.Fatalf("want 1 hit and 1 miss, get %d and %d", hits, misses)
+	}
+
+	// float operations recorded with increment and decrement labels
+	if _, err := c.IncrementFloat("test-metrics-missing", 1.5); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.DecrementFloat("test-metrics-missing", 1.5); err != nil {
+		t.Fatal(err)
+	}
+
+	if inc, dec := metrics.Count("cache", "redis", "increment", "miss"), metrics.Count("cache", "redis", "decrement", "miss"); inc != 1 || dec != 1 {
+		t.Fatalf("want float operations recorded as increment and decrement, get %d and %d", inc, dec)
+	}
+
+	q := cache.NewMetricsQueue(cache.NewRedisQueue("test-metrics-queue", redis.Options{Addr: "localhost:6379"}), "redis", metrics)
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if misses := metrics.Count("queue", "redis", "pull", "miss"); misses != 1 {
+		t.Fatalf("want 1 empty pull, get %d", misses)
+	}
+
+	stream, err := cache.NewRedisStreamQueue("test-metrics-stream", "workers", "w1", redis.Options{Addr: "localhost:6379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.NewMetricsQueue(stream, "stream", metrics).(cache.StreamQueue); !ok {
+		t.Fatal("wrapped stream queue must implement StreamQueue")
+	}
+
+	sq := cache.NewMetricsStreamQueue(stream, "stream", metrics)
+	if err := sq.Push("job"); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgs, err := sq.Read(10); err != nil || len(msgs) == 0 {
+		t.Fatalf("failed read %v", err)
+	} else if err := sq.Ack(msgs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sq.TrimLen(0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"push", "ack", "trim"} {
+		if metrics.Count("queue", "stream", op, "ok") != 1 {
+			t.Fatalf("stream %s must recorded", op)
+		}
+	}
+
+	if metrics.Count("queue", "stream", "read", "hit") != 1 {
+		t.Fatal("stream read must recorded")
+	}
+
+	limiter, err := cache.NewRateLimiter("test-metrics-limiter", 1, time.Minute, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter = cache.NewMetricsRateLimiter(limiter, "fixed", metrics)
+	if err := limiter.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := limiter.Attempt(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if allowed, denied := metrics.Count("ratelimiter", "fixed", "attempt", "allowed"), metrics.Count("ratelimiter", "fixed", "attempt", "denied"); allowed != 1 || denied != 1 {
+		t.Fatalf("want 1 allowed and 1 denied attempt, get %d and %d", allowed, denied)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE cache_operations_total counter",
+		`cache_operations_total{driver="redis",op="get",result="hit"} 1`,
+		`cache_operation_duration_seconds_bucket{driver="redis",op="get",le="+Inf"} 2`,
+		`cache_operation_duration_seconds_count{driver="redis",op="get"} 2`,
+		`queue_operations_total{driver="redis",op="pull",result="miss"} 1`,
+		`ratelimiter_operations_total{driver="fixed",op="attempt",result="denied"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("metrics must contain %q\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+// metricResult get operation result, hit or miss used for lookup operations
+func metricResult(err error, found ...bool) string {
+	if err != nil {
+		return "error"
+	} else if len(found) == 0 {
+		return "ok"
+	} else if found[0] {
+		return "hit"
+	}
+	return "miss"
+}
+
+// MetricsMiddleware create middleware recording cache calls metrics with driver label
+func MetricsMiddleware(driver string, metrics Metrics) CacheMiddleware {
+	return func(call CacheCall, next CacheHandler) (any, error) {
+		if call.Op == "map_key" {
+			return keyMapper(nil), nil
+		}
+
+		// float operations recorded as increment and decrement
+		start := time.Now()
+		v, err := next(call)
+		metrics.Observe("cache", driver, strings.TrimSuffix(call.Op, "_float"), callResult(call, v, err), time.Since(start))
+		return v, err
+	}
+}
+
+// mQueue queue wrapper recording operation metrics
+type mQueue struct {
+	queue   Queue
+	driver  string
+	metrics Metrics
+}
+
+func (mq mQueue) observe(op string, start time.Time, err error, found ...bool) {
+	mq.metrics.Observe("queue", mq.driver, op, metricResult(err, found...), time.Since(start))
+}
+
+func (mq mQueue) Push(value any) error {
+	start := time.Now()
+	err := mq.queue.Push(value)
+	mq.observe("push", start, err)
+	return err
+}
+
+func (mq mQueue) PushUnique(key string, value any, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	pushed, err := mq.queue.PushUnique(key, value, ttl)
+	mq.observe("push_unique", start, err, pushed)
+	return pushed, err
+}
+
+func (mq mQueue) Pull() (*string, error) {
+	start := time.Now()
+	v, err := mq.queue.Pull()
+	mq.observe("pull", start, err, v != nil)
+	return v, err
+}
+
+func (mq mQueue) Len() (int64, error) {
+	start := time.Now()
+	n, err := mq.queue.Len()
+	mq.observe("len", start, err)
+	return n, err
+}
+
+func (mq mQueue) Peek(n int64) ([]string, error) {
+	start := time.Now()
+	items, err := mq.queue.Peek(n)
+	mq.observe("peek", start, err)
+	return items, err
+}
+
+func (mq mQueue) Remove(value any) (int64, error) {
+	start := time.Now()
+	n, err := mq.queue.Remove(value)
+	mq.observe("remove", start, err)
+	return n, err
+}
+
+func (mq mQueue) Purge() error {
+	start := time.Now()
+	err := mq.queue.Purge()
+	mq.observe("purge", start, err)
+	return err
+}
+
+func (mq mQueue) Stats() (QueueStats, error) {
+	start := time.Now()
+	stats, err := mq.queue.Stats()
+	mq.observe("stats", start, err)
+	return stats, err
+}
+
+// mStreamQueue stream queue wrapper recording operation metrics
+type mStreamQueue struct {
+	mQueue
+	stream StreamQueue
+}
+
+func (mq mStreamQueue) Read(n int64) ([]StreamMessage, error) {
+	start := time.Now()
+	msgs, err := mq.stream.Read(n)
+	mq.observe("read", start, err, len(msgs) > 0)
+	return msgs, err
+}
+
+func (mq mStreamQueue) Ack(ids ...string) error {
+	start := time.Now()
+	err := mq.stream.Ack(ids...)
+	mq.observe("ack", start, err)
+	return err
+}
+
+func (mq mStreamQueue) Pending(n int64) ([]PendingMessage, error) {
+	start := time.Now()
+	pending, err := mq.stream.Pending(n)
+	mq.observe("pending", start, err)
+	return pending, err
+}
+
+func (mq mStreamQueue) Claim(minIdle time.Duration, n int64) ([]StreamMessage, error) {
+	start := time.Now()
+	msgs, err := mq.stream.Claim(minIdle, n)
+	mq.observe("claim", start, err, len(msgs) > 0)
+	return msgs, err
+}
+
+func (mq mStreamQueue) TrimLen(maxLen int64) (int64, error) {
+	start := time.Now()
+	n, err := mq.stream.TrimLen(maxLen)
+	mq.observe("trim", start, err)
+	return n, err
+}
+
+func (mq mStreamQueue) TrimAge(age time.Duration) (int64, error) {
+	start := time.Now()
+	n, err := mq.stream.TrimAge(age)
+	mq.observe("trim", start, err)
+	return n, err
+}
+
+// Group get group stream queue recording metrics with same driver label
+func (mq mStreamQueue) Group(group, consumer string) (StreamQueue, error) {
+	q, err := mq.stream.Group(group, consumer)
+	if err != nil {
+		return nil, err
+	}
+	return NewMetricsStreamQueue(q, mq.driver, mq.metrics), nil
+}
+
+// mLimiter rate limiter wrapper recording operation metrics, attempts
+// recorded as allowed or denied
+type mLimiter struct {
+	limiter RateLimiter
+	driver  string
+	metrics Metrics
+}
+
+func (ml mLimiter) observe(op string, start time.Time, err error) {
+	ml.metrics.Observe("ratelimiter", ml.driver, op, metricResult(err), time.Since(start))
+}
+
+func (ml mLimiter) Hit() error {
+	start := time.Now()
+	err := ml.limiter.Hit()
+	ml.observe("hit", start, err)
+	return err
+}
+
+func (ml mLimiter) Lock() error {
+	start := time.Now()
+	err := ml.limiter.Lock()
+	ml.observe("lock", start, err)
+	return err
+}
+
+func (ml mLimiter) Reset() error {
+	start := time.Now()
+	err := ml.limiter.Reset()
+	ml.observe("reset", start, err)
+	return err
+}
+
+func (ml mLimiter) Clear() error {
+	start := time.Now()
+	err := ml.limiter.Clear()
+	ml.observe("clear", start, err)
+	return err
+}
+
+func (ml mLimiter) MustLock() (bool, error) {
+	start := time.Now()
+	locked, err := ml.limiter.MustLock()
+	ml.observe("must_lock", start, err)
+	return locked, err
+}
+
+func (ml mLimiter) TotalAttempts() (uint32, error) {
+	start := time.Now()
+	total, err := ml.limiter.TotalAttempts()
+	ml.observe("total_attempts", start, err)
+	return total, err
+}
+
+func (ml mLimiter) RetriesLeft() (uint32, error) {
+	start := time.Now()
+	left, err := ml.limiter.RetriesLeft()
+	ml.observe("retries_left", start, err)
+	return left, err
+}
+
+func (ml mLimiter) AvailableIn() (time.Duration, error) {
+	start := time.Now()
+	ttl, err := ml.limiter.AvailableIn()
+	ml.observe("available_in", start, err)
+	return ttl, err
+}
+
+func (ml mLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	start := time.Now()
+	allowed, left, wait, err := ml.limiter.Attempt()
+	result := "allowed"
+	if err != nil {
+		result = "error"
+	} else if !allowed {
+		result = "denied"
+	}
+	ml.metrics.Observe("ratelimiter", ml.driver, "attempt", result, time.Since(start))
+	return allowed, left, wait, err
+}
@@ -0,0 +1,26 @@
+package cache
+
+import "time"
+
+// TokenInfo one time token data
+type TokenInfo struct {
+	// Subject token owner (user id, email, ...)
+	Subject string `json:"subject"`
+	// Payload token data (redirect url, action, ...)
+	Payload string `json:"payload"`
+	// IssuedAt token issue time
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// OneTimeToken interface for one time url safe tokens (magic links, password reset, ...).
+// only token hash stored in cache and token consumed on verify
+type OneTimeToken interface {
+	// Issue issue new token for subject with payload
+	Issue(subject string, payload string) (string, error)
+	// Verify verify and consume token, return nil if token invalid, expired or revoked
+	Verify(token string) (*TokenInfo, error)
+	// Revoke revoke token
+	Revoke(token string) error
+	// RevokeAll revoke all issued tokens of subject
+	RevokeAll(subject string) error
+}
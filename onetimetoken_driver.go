@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// get subject generation and refresh generation ttl to outlive new token,
+// generation increased on revoke operation
+var ottGenerationScript = redis.NewScript(`
+local gen = tonumber(redis.call("GET", KEYS[1])) or 0
+if ARGV[1] == "revoke" then
+	gen = gen + 1
+	redis.call("SET", KEYS[1], gen, "PX", ARGV[2])
+elseif gen > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return gen
+`)
+
+// ottRecord stored token record
+type ottRecord struct {
+	TokenInfo
+	Generation int64 `json:"generation"`
+}
+
+// ottDriver one time token. token stored by sha256 hash with subject generation,
+// revoke all increase subject generation and tokens of older generations rejected.
+// generation live for ttl after last issue or revoke, so it outlive all tokens.
+type ottDriver struct {
+	name   string
+	ttl    time.Duration
+	cache  Cache
+	client *rCache
+}
+
+func (ot ottDriver) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"OneTimeToken", ot.name}, pattern, params...)
+}
+
+func (ot *ottDriver) init(name string, ttl time.Duration, cache Cache) error {
+	if ttl < time.Millisecond {
+		return ot.err("invalid ttl %s", ttl)
+	}
+
+	ot.name = name
+	ot.ttl = ttl
+	ot.cache = cache
+	ot.client, _ = redisOf(cache, name)
+	return nil
+}
+
+func (ot ottDriver) tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return utils.ConcatStr("-", ot.name, hex.EncodeToString(sum[:]))
+}
+
+func (ot ottDriver) generationKey(subject string) string {
+	return utils.ConcatStr("-", ot.name, "generation", subject)
+}
+
+// generation get subject generation for issue or increase it for revoke operation
+func (ot ottDriver) generation(subject, op string) (int64, error) {
+	if ot.client != nil {
+		_, key := redisOf(ot.cache, ot.generationKey(subject))
+		gen, err := ot.client.run(ottGenerationScript, []string{key}, op, ot.ttl.Milliseconds()).Int64()
+		if err != nil {
+			return 0, ot.err(err.Error())
+		}
+		return gen, nil
+	}
+
+	defer lockKey(ot.generationKey(subject))()
+	caster, err := ot.cache.Cast(ot.generationKey(subject))
+	if err != nil {
+		return 0, ot.err(err.Error())
+	}
+
+	gen := caster.Int64Safe(0)
+	if op == "revoke" {
+		gen++
+	} else if gen == 0 {
+		return 0, nil
+	}
+
+	if err := ot.cache.Put(ot.generationKey(subject), gen, ot.ttl); err != nil {
+		return 0, ot.err(err.Error())
+	}
+	return gen, nil
+}
+
+// pull get and remove token record atomically
+func (ot ottDriver) pull(token string) (string, error) {
+	if ot.client != nil {
+		_, key := redisOf(ot.cache, ot.tokenKey(token))
+		var v string
+		err := ot.client.do(false, func(ctx context.Context, client *redis.Client) (err error) {
+			v, err = client.GetDel(ctx, key).Result()
+			return err
+		})
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		} else if err != nil {
+			return "", ot.err(err.Error())
+		}
+		return v, nil
+	}
+
+	defer lockKey(ot.tokenKey(token))()
+	if caster, err := ot.cache.Cast(ot.tokenKey(token)); err != nil {
+		return "", ot.err(err.Error())
+	} else if caster.IsNil() {
+		return "", nil
+	} else if err := ot.cache.Forget(ot.tokenKey(token)); err != nil {
+		return "", ot.err(err.Error())
+	} else {
+		return caster.StringSafe(""), nil
+	}
+}
+
+func (ot ottDriver) Issue(subject string, payload string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", ot.err(err.Error())
+	}
+
+	gen, err := ot.generation(subject, "issue")
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(ottRecord{
+		TokenInfo: TokenInfo{
+			Subject:  subject,
+			Payload:  payload,
+			IssuedAt: time.Now(),
+		},
+		Generation: gen,
+	})
+	if err != nil {
+		return "", ot.err(err.Error())
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	if err := ot.cache.Put(ot.tokenKey(token), string(encoded), ot.ttl); err != nil {
+		return "", ot.err(err.Error())
+	}
+	return token, nil
+}
+
+func (ot ottDriver) Verify(token string) (*TokenInfo, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	v, err := ot.pull(token)
+	if err != nil || v == "" {
+		return nil, err
+	}
+
+	rec := new(ottRecord)
+	if err := json.Unmarshal([]byte(v), rec); err != nil {
+		return nil, ot.err(err.Error())
+	}
+
+	caster, err := ot.cache.Cast(ot.generationKey(rec.Subject))
+	if err != nil {
+		return nil, ot.err(err.Error())
+	}
+
+	if caster.Int64Safe(0) != rec.Generation {
+		return nil, nil
+	}
+	return &rec.TokenInfo, nil
+}
+
+func (ot ottDriver) Revoke(token string) error {
+	if err := ot.cache.Forget(ot.tokenKey(token)); err != nil {
+		return ot.err(err.Error())
+	}
+	return nil
+}
+
+func (ot ottDriver) RevokeAll(subject string) error {
+	_, err := ot.generation(subject, "revoke")
+	return err
+}
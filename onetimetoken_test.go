@@ -0,0 +1,108 @@
+package cache_test
+
+import (
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestOneTimeToken(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		tokens, err := cache.NewOneTimeToken("test-magic", time.Minute, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := tokens.Issue("john", "/dashboard")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if url.QueryEscape(token) != token {
+			t.Fatalf("%s: token must be url safe %s", name, token)
+		}
+
+		if info, err := tokens.Verify(token); err != nil || info == nil {
+			t.Fatalf("%s: valid token must verify %v", name, err)
+		} else if info.Subject != "john" || info.Payload != "/dashboard" {
+			t.Fatalf("%s: invalid token info %v", name, info)
+		}
+
+		if info, err := tokens.Verify(token); err != nil || info != nil {
+			t.Fatalf("%s: consumed token must fail %v", name, err)
+		}
+
+		// revoke
+		token, err = tokens.Issue("john", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tokens.Revoke(token); err != nil {
+			t.Fatal(err)
+		}
+
+		if info, err := tokens.Verify(token); err != nil || info != nil {
+			t.Fatalf("%s: revoked token must fail %v", name, err)
+		}
+
+		// revoke all subject tokens
+		first, _ := tokens.Issue("john", "")
+		second, _ := tokens.Issue("john", "")
+		other, _ := tokens.Issue("jane", "")
+		if err := tokens.RevokeAll("john"); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tk := range []string{first, second} {
+			if info, err := tokens.Verify(tk); err != nil || info != nil {
+				t.Fatalf("%s: subject tokens must revoked %v", name, err)
+			}
+		}
+
+		if info, err := tokens.Verify(other); err != nil || info == nil {
+			t.Fatalf("%s: other subject token must verify %v", name, err)
+		}
+
+		if token, err := tokens.Issue("john", ""); err != nil {
+			t.Fatal(err)
+		} else if info, err := tokens.Verify(token); err != nil || info == nil {
+			t.Fatalf("%s: token issued after revoke must verify %v", name, err)
+		}
+	}
+}
+
+func TestOneTimeTokenRevokeGenerations(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		tokens, err := cache.NewOneTimeToken("test-generation", time.Minute, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// tokens issued right after revoke not affected by clock resolution
+		for i := 0; i < 10; i++ {
+			old, _ := tokens.Issue("john", "")
+			if err := tokens.RevokeAll("john"); err != nil {
+				t.Fatal(err)
+			}
+
+			fresh, err := tokens.Issue("john", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info, err := tokens.Verify(old); err != nil || info != nil {
+				t.Fatalf("%s: token of old generation must revoked %v", name, err)
+			}
+
+			if info, err := tokens.Verify(fresh); err != nil || info == nil {
+				t.Fatalf("%s: token issued after revoke must verify %v", name, err)
+			}
+		}
+	}
+}
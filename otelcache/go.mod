@@ -0,0 +1,11 @@
+module github.com/gomig/cache/otelcache
+
+go 1.21
+
+require (
+	github.com/gomig/cache v1.0.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+replace github.com/gomig/cache => ../
@@ -0,0 +1,61 @@
+// Package otelcache adapt opentelemetry tracer to cache tracing hooks
+package otelcache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomig/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer adapt opentelemetry tracer to cache Tracer, spans created as client spans
+func Tracer(tracer trace.Tracer) cache.Tracer {
+	return otelTracer{tracer: tracer}
+}
+
+// SpanHook create cache span hook recording operations with opentelemetry tracer
+func SpanHook(tracer trace.Tracer) cache.CacheHook {
+	return cache.SpanHook(Tracer(tracer))
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+func (t otelTracer) Start(ctx context.Context, name string) (context.Context, cache.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttribute(key string, value any) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
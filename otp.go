@@ -0,0 +1,64 @@
+package cache
+
+import "time"
+
+// OTP interface for one time password (TOTP RFC 6238 and HOTP RFC 4226).
+// secret and last used step stored in cache and each code accepted once
+type OTP interface {
+	// GenerateSecret generate and store new random secret, return base32 encoded secret
+	GenerateSecret() (string, error)
+	// SetSecret store base32 encoded secret
+	SetSecret(secret string) error
+	// Code get current code, next unused counter code for HOTP
+	Code() (string, error)
+	// Verify verify code in skew window, used code and older codes rejected
+	Verify(code string) (bool, error)
+	// URI get otpauth provisioning uri for authenticator apps
+	URI(issuer, account string) (string, error)
+	// Clear remove secret and used code marker
+	Clear() error
+}
+
+// OTPAlgorithm otp hmac hash algorithm
+type OTPAlgorithm string
+
+const (
+	// OTPSHA1 hmac-sha1 algorithm, supported by all authenticator apps
+	OTPSHA1 OTPAlgorithm = "SHA1"
+	// OTPSHA256 hmac-sha256 algorithm
+	OTPSHA256 OTPAlgorithm = "SHA256"
+	// OTPSHA512 hmac-sha512 algorithm
+	OTPSHA512 OTPAlgorithm = "SHA512"
+)
+
+// OTPOption otp option
+type OTPOption func(*otpDriver)
+
+// OTPDigits set code digits between 6 and 8, default is 6
+func OTPDigits(digits uint) OTPOption {
+	return func(o *otpDriver) {
+		o.digits = digits
+	}
+}
+
+// OTPPeriod set TOTP time step in whole seconds, default is 30 seconds
+func OTPPeriod(period time.Duration) OTPOption {
+	return func(o *otpDriver) {
+		o.period = period
+	}
+}
+
+// OTPSkew set accepted drift steps, past and future steps for TOTP and
+// look ahead counters for HOTP. default is 1
+func OTPSkew(steps uint) OTPOption {
+	return func(o *otpDriver) {
+		o.skew = steps
+	}
+}
+
+// OTPHash set hmac algorithm, default is OTPSHA1
+func OTPHash(algorithm OTPAlgorithm) OTPOption {
+	return func(o *otpDriver) {
+		o.algorithm = algorithm
+	}
+}
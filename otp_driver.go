@@ -0,0 +1,308 @@
+package cache
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// set last used step if newer than stored step
+var otpUseScript = redis.NewScript(`
+local last = tonumber(redis.call("GET", KEYS[1]))
+if last and last >= tonumber(ARGV[1]) then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+var otpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// otpDriver one time password. step is time step for TOTP and counter for HOTP.
+// last used step stored to reject replayed and older codes.
+type otpDriver struct {
+	key       string
+	timeBased bool
+	digits    uint
+	period    time.Duration
+	skew      uint
+	algorithm OTPAlgorithm
+	cache     Cache
+	client    *rCache
+}
+
+func (o otpDriver) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"OTP", o.key}, pattern, params...)
+}
+
+func (o otpDriver) notExistsErr() error {
+	return utils.TaggedError([]string{"OTP", "NotExists", o.key}, "%s secret not exists", o.key)
+}
+
+func (o *otpDriver) init(key string, timeBased bool, cache Cache, options ...OTPOption) error {
+	o.key = key
+	o.timeBased = timeBased
+	o.digits = 6
+	o.period = 30 * time.Second
+	o.skew = 1
+	o.algorithm = OTPSHA1
+	o.cache = cache
+	o.client, _ = redisOf(cache, key)
+	for _, option := range options {
+		option(o)
+	}
+
+	if o.digits < 6 || o.digits > 8 {
+		return o.err("invalid digits %d", o.digits)
+	}
+
+	// period used as whole seconds by step and otpauth uri
+	if o.period < time.Second || o.period%time.Second != 0 {
+		return o.err("invalid period %s", o.period)
+	}
+
+	if o.hasher() == nil {
+		return o.err("invalid algorithm %s", o.algorithm)
+	}
+	return nil
+}
+
+func (o otpDriver) secretKey() string {
+	return utils.ConcatStr("-", o.key, "secret")
+}
+
+func (o otpDriver) lastKey() string {
+	return utils.ConcatStr("-", o.key, "last")
+}
+
+func (o otpDriver) hasher() func() hash.Hash {
+	switch o.algorithm {
+	case OTPSHA1:
+		return sha1.New
+	case OTPSHA256:
+		return sha256.New
+	case OTPSHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// secret get decoded secret
+func (o otpDriver) secret() ([]byte, error) {
+	caster, err := o.cache.Cast(o.secretKey())
+	if err != nil {
+		return nil, o.err(err.Error())
+	}
+
+	if caster.IsNil() {
+		return nil, o.notExistsErr()
+	}
+
+	secret, err := otpEncoding.DecodeString(caster.StringSafe(""))
+	if err != nil {
+		return nil, o.err(err.Error())
+	}
+	return secret, nil
+}
+
+// code generate code of step (RFC 4226)
+func (o otpDriver) code(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(o.hasher(), secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := uint(0); i < o.digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", o.digits, value%mod)
+}
+
+// last get last used step, -1 if no code used
+func (o otpDriver) last() (int64, error) {
+	if caster, err := o.cache.Cast(o.lastKey()); err != nil {
+		return 0, o.err(err.Error())
+	} else {
+		return caster.Int64Safe(-1), nil
+	}
+}
+
+// step get current time step
+func (o otpDriver) step() int64 {
+	return time.Now().Unix() / int64(o.period.Seconds())
+}
+
+// use mark step as last used step, return false if step or newer step used before
+func (o otpDriver) use(step int64) (bool, error) {
+	// TOTP marker kept while step in skew window
+	var ttl time.Duration
+	if o.timeBased {
+		ttl = time.Duration(2*o.skew+2) * o.period
+	}
+
+	if o.client != nil {
+		_, lastKey := redisOf(o.cache, o.lastKey())
+		res, err := o.client.run(otpUseScript, []string{lastKey}, step, ttl.Milliseconds()).Int()
+		if err != nil {
+			return false, o.err(err.Error())
+		}
+		return res == 1, nil
+	}
+
+	defer lockKey(o.lastKey())()
+	if last, err := o.last(); err != nil || last >= step {
+		return false, err
+	}
+
+	var err error
+	if ttl > 0 {
+		err = o.cache.Put(o.lastKey(), step, ttl)
+	} else {
+		err = o.cache.PutForever(o.lastKey(), step)
+	}
+
+	if err != nil {
+		return false, o.err(err.Error())
+	}
+	return true, nil
+}
+
+func (o otpDriver) GenerateSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", o.err(err.Error())
+	}
+
+	encoded := otpEncoding.EncodeToString(secret)
+	return encoded, o.SetSecret(encoded)
+}
+
+func (o otpDriver) SetSecret(secret string) error {
+	secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	if _, err := otpEncoding.DecodeString(secret); err != nil || secret == "" {
+		return o.err("invalid secret")
+	}
+
+	if err := o.cache.PutForever(o.secretKey(), secret); err != nil {
+		return o.err(err.Error())
+	}
+
+	// new secret start with no used code
+	if err := o.cache.Forget(o.lastKey()); err != nil {
+		return o.err(err.Error())
+	}
+	return nil
+}
+
+func (o otpDriver) Code() (string, error) {
+	secret, err := o.secret()
+	if err != nil {
+		return "", err
+	}
+
+	if o.timeBased {
+		return o.code(secret, o.step()), nil
+	}
+
+	if last, err := o.last(); err != nil {
+		return "", err
+	} else {
+		return o.code(secret, last+1), nil
+	}
+}
+
+func (o otpDriver) Verify(code string) (bool, error) {
+	secret, err := o.secret()
+	if err != nil {
+		return false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != int(o.digits) {
+		return false, nil
+	}
+
+	last, err := o.last()
+	if err != nil {
+		return false, err
+	}
+
+	from, to := last+1, last+1+int64(o.skew)
+	if o.timeBased {
+		step := o.step()
+		from, to = max(last+1, step-int64(o.skew)), step+int64(o.skew)
+	}
+
+	for step := from; step <= to; step++ {
+		if subtle.ConstantTimeCompare([]byte(o.code(secret, step)), []byte(code)) == 1 {
+			return o.use(step)
+		}
+	}
+	return false, nil
+}
+
+func (o otpDriver) URI(issuer, account string) (string, error) {
+	caster, err := o.cache.Cast(o.secretKey())
+	if err != nil {
+		return "", o.err(err.Error())
+	}
+
+	if caster.IsNil() {
+		return "", o.notExistsErr()
+	}
+
+	params := url.Values{}
+	params.Set("secret", caster.StringSafe(""))
+	params.Set("algorithm", string(o.algorithm))
+	params.Set("digits", strconv.FormatUint(uint64(o.digits), 10))
+	if issuer != "" {
+		params.Set("issuer", issuer)
+		account = issuer + ":" + account
+	}
+
+	kind := "hotp"
+	if o.timeBased {
+		kind = "totp"
+		params.Set("period", strconv.FormatInt(int64(o.period.Seconds()), 10))
+	} else if last, err := o.last(); err != nil {
+		return "", err
+	} else {
+		params.Set("counter", strconv.FormatInt(last+1, 10))
+	}
+
+	return "otpauth://" + kind + "/" + url.PathEscape(account) + "?" + params.Encode(), nil
+}
+
+func (o otpDriver) Clear() error {
+	if err := o.cache.Forget(o.secretKey()); err != nil {
+		return o.err(err.Error())
+	}
+
+	if err := o.cache.Forget(o.lastKey()); err != nil {
+		return o.err(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package cache_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestHOTP(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		otp, err := cache.NewHOTP("test-hotp", c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// RFC 4226 test secret "12345678901234567890"
+		if err := otp.SetSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+			t.Fatal(err)
+		}
+
+		if code, err := otp.Code(); err != nil || code != "755224" {
+			t.Fatalf("%s: want 755224, get %s %v", name, code, err)
+		}
+
+		if ok, err := otp.Verify("755224"); err != nil || !ok {
+			t.Fatalf("%s: valid code must verify %v", name, err)
+		}
+
+		if ok, err := otp.Verify("755224"); err != nil || ok {
+			t.Fatalf("%s: replayed code must fail %v", name, err)
+		}
+
+		// counter 2 accepted in look ahead window
+		if ok, err := otp.Verify("359152"); err != nil || !ok {
+			t.Fatalf("%s: look ahead code must verify %v", name, err)
+		}
+
+		if ok, err := otp.Verify("287082"); err != nil || ok {
+			t.Fatalf("%s: skipped code must fail %v", name, err)
+		}
+
+		if code, err := otp.Code(); err != nil || code != "969429" {
+			t.Fatalf("%s: want 969429, get %s %v", name, code, err)
+		}
+
+		if err := otp.Clear(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		otp, err := cache.NewTOTP("test-totp", c, cache.OTPDigits(8), cache.OTPHash(cache.OTPSHA256))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secret, err := otp.GenerateSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, err := otp.Code()
+		if err != nil || len(code) != 8 {
+			t.Fatalf("%s: invalid code %s %v", name, code, err)
+		}
+
+		if ok, err := otp.Verify(code); err != nil || !ok {
+			t.Fatalf("%s: valid code must verify %v", name, err)
+		}
+
+		if ok, err := otp.Verify(code); err != nil || ok {
+			t.Fatalf("%s: replayed code must fail %v", name, err)
+		}
+
+		uri, err := otp.URI("My App", "john@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(uri, "otpauth://totp/My%20App:john@example.com?") ||
+			!strings.Contains(uri, "secret="+secret) ||
+			!strings.Contains(uri, "algorithm=SHA256") ||
+			!strings.Contains(uri, "digits=8") ||
+			!strings.Contains(uri, "period=30") {
+			t.Fatalf("%s: invalid uri %s", name, uri)
+		}
+
+		if err := otp.Clear(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := otp.Code(); err == nil {
+			t.Fatalf("%s: code must fail without secret", name)
+		}
+	}
+}
+
+func TestTOTPPeriod(t *testing.T) {
+	for _, period := range []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond} {
+		if _, err := cache.NewTOTP("test-totp-period", redisCache(), cache.OTPPeriod(period)); err == nil {
+			t.Fatalf("%s: period must be whole seconds", period)
+		}
+	}
+
+	if _, err := cache.NewTOTP("test-totp-period", redisCache(), cache.OTPPeriod(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
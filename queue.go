@@ -1,9 +1,36 @@
 package cache
 
+import "time"
+
+// QueueStats queue statistics snapshot
+type QueueStats struct {
+	// Length total items waiting in queue
+	Length int64
+	// Enqueued total items pushed to queue
+	Enqueued int64
+	// Dequeued total items pulled from queue
+	Dequeued int64
+	// OldestAge age of the oldest waiting item, zero if queue is empty
+	OldestAge time.Duration
+}
+
 // Queue interface for queue drivers.
 type Queue interface {
 	// Push queue new item
 	Push(value any) error
+	// PushUnique queue new item if no pending item pushed with same dedup key,
+	// dedup key released when item consumed or ttl passed (zero ttl never expire)
+	PushUnique(key string, value any, ttl time.Duration) (bool, error)
 	// Pull read first queue item
 	Pull() (*string, error)
+	// Len get total items waiting in queue
+	Len() (int64, error)
+	// Peek read first n queue items without removing them
+	Peek(n int64) ([]string, error)
+	// Remove delete all items equal to value from queue, return removed count
+	Remove(value any) (int64, error)
+	// Purge delete all queue items
+	Purge() error
+	// Stats get queue statistics
+	Stats() (QueueStats, error)
 }
@@ -3,11 +3,108 @@ package cache
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/gomig/utils"
 	"github.com/redis/go-redis/v9"
 )
 
+// release dedup marker of unique item if marker still owned by item
+const rqReleaseLua = `
+local function release(u)
+	if not u or u == "" then
+		return
+	end
+	local token, marker = string.match(u, "^(%d+) (.*)$")
+	if marker and redis.call("GET", marker) == token then
+		redis.call("DEL", marker)
+	end
+end
+local function entries(v)
+	local meta = redis.call("HGET", KEYS[2], v)
+	if not meta then
+		return {}
+	end
+	return cjson.decode(meta)
+end
+`
+
+// queue items pushed to left of list, push time and dedup marker of items kept
+// in meta hash by item value as fifo of [time, marker] entries. items without
+// entry (pushed by plain LPUSH or before meta) have unknown push time
+var rqPushScript = redis.NewScript(rqReleaseLua + `
+if KEYS[4] and redis.call("EXISTS", KEYS[4]) == 1 then
+	return 0
+end
+local token = redis.call("HINCRBY", KEYS[3], "enqueued", 1)
+local unique = ""
+if KEYS[4] then
+	if tonumber(ARGV[3]) > 0 then
+		redis.call("SET", KEYS[4], token, "PX", ARGV[3])
+	else
+		redis.call("SET", KEYS[4], token)
+	end
+	unique = token .. " " .. KEYS[4]
+end
+local items = entries(ARGV[1])
+table.insert(items, {tonumber(ARGV[2]), unique})
+redis.call("LPUSH", KEYS[1], ARGV[1])
+redis.call("HSET", KEYS[2], ARGV[1], cjson.encode(items))
+return 1
+`)
+
+var rqPullScript = redis.NewScript(rqReleaseLua + `
+local v = redis.call("RPOP", KEYS[1])
+if not v then
+	return false
+end
+local items = entries(v)
+if #items > 0 then
+	release(table.remove(items, 1)[2])
+end
+if #items > 0 then
+	redis.call("HSET", KEYS[2], v, cjson.encode(items))
+else
+	redis.call("HDEL", KEYS[2], v)
+end
+redis.call("HINCRBY", KEYS[3], "dequeued", 1)
+return v
+`)
+
+var rqRemoveScript = redis.NewScript(rqReleaseLua + `
+local removed = redis.call("LREM", KEYS[1], 0, ARGV[1])
+if removed > 0 then
+	for _, item in ipairs(entries(ARGV[1])) do
+		release(item[2])
+	end
+	redis.call("HDEL", KEYS[2], ARGV[1])
+end
+return removed
+`)
+
+var rqPurgeScript = redis.NewScript(rqReleaseLua + `
+for _, meta in ipairs(redis.call("HVALS", KEYS[2])) do
+	for _, item in ipairs(cjson.decode(meta)) do
+		release(item[2])
+	end
+end
+redis.call("DEL", KEYS[1], KEYS[2])
+return 1
+`)
+
+var rqStatsScript = redis.NewScript(rqReleaseLua + `
+local oldest = 0
+local head = redis.call("LINDEX", KEYS[1], -1)
+if head then
+	local items = entries(head)
+	if #items > 0 then
+		oldest = items[1][1]
+	end
+end
+local counters = redis.call("HMGET", KEYS[3], "enqueued", "dequeued")
+return {redis.call("LLEN", KEYS[1]), tonumber(counters[1]) or 0, tonumber(counters[2]) or 0, oldest}
+`)
+
 type rQueue struct {
 	name   string
 	client *redis.Client
@@ -22,15 +119,52 @@ func (rq *rQueue) init(name string, opt redis.Options) {
 	rq.client = redis.NewClient(&opt)
 }
 
+func (rq rQueue) metaKey() string {
+	return utils.ConcatStr("-", rq.name, "meta")
+}
+
+func (rq rQueue) statsKey() string {
+	return utils.ConcatStr("-", rq.name, "stats")
+}
+
+func (rq rQueue) markerKey(key string) string {
+	return utils.ConcatStr("-", rq.name, "unique", key)
+}
+
+func (rq rQueue) keys() []string {
+	return []string{rq.name, rq.metaKey(), rq.statsKey()}
+}
+
 func (rq rQueue) Push(value any) error {
-	if err := rq.client.LPush(context.TODO(), rq.name, value).Err(); err != nil {
+	if err := rqPushScript.Run(
+		context.TODO(),
+		rq.client,
+		rq.keys(),
+		value,
+		time.Now().UnixMilli(),
+	).Err(); err != nil {
 		return rq.err(err.Error())
 	}
 	return nil
 }
 
+func (rq rQueue) PushUnique(key string, value any, ttl time.Duration) (bool, error) {
+	if pushed, err := rqPushScript.Run(
+		context.TODO(),
+		rq.client,
+		append(rq.keys(), rq.markerKey(key)),
+		value,
+		time.Now().UnixMilli(),
+		ttl.Milliseconds(),
+	).Int(); err != nil {
+		return false, rq.err(err.Error())
+	} else {
+		return pushed == 1, nil
+	}
+}
+
 func (rq rQueue) Pull() (*string, error) {
-	v, err := rq.client.RPop(context.TODO(), rq.name).Result()
+	v, err := rqPullScript.Run(context.TODO(), rq.client, rq.keys()).Text()
 
 	if errors.Is(err, redis.Nil) {
 		return nil, nil
@@ -42,3 +176,61 @@ func (rq rQueue) Pull() (*string, error) {
 		return &v, nil
 	}
 }
+
+func (rq rQueue) Len() (int64, error) {
+	if l, err := rq.client.LLen(context.TODO(), rq.name).Result(); err != nil {
+		return 0, rq.err(err.Error())
+	} else {
+		return l, nil
+	}
+}
+
+func (rq rQueue) Peek(n int64) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	items, err := rq.client.LRange(context.TODO(), rq.name, -n, -1).Result()
+	if err != nil {
+		return nil, rq.err(err.Error())
+	}
+
+	// list tail is the queue head
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items, nil
+}
+
+func (rq rQueue) Remove(value any) (int64, error) {
+	if removed, err := rqRemoveScript.Run(
+		context.TODO(),
+		rq.client,
+		rq.keys(),
+		value,
+	).Int64(); err != nil {
+		return 0, rq.err(err.Error())
+	} else {
+		return removed, nil
+	}
+}
+
+func (rq rQueue) Purge() error {
+	if err := rqPurgeScript.Run(context.TODO(), rq.client, rq.keys()).Err(); err != nil {
+		return rq.err(err.Error())
+	}
+	return nil
+}
+
+func (rq rQueue) Stats() (QueueStats, error) {
+	res, err := rqStatsScript.Run(context.TODO(), rq.client, rq.keys()).Int64Slice()
+	if err != nil {
+		return QueueStats{}, rq.err(err.Error())
+	}
+
+	stats := QueueStats{Length: res[0], Enqueued: res[1], Dequeued: res[2]}
+	if res[3] > 0 && stats.Length > 0 {
+		stats.OldestAge = time.Since(time.UnixMilli(res[3]))
+	}
+	return stats, nil
+}
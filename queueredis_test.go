@@ -1,8 +1,10 @@
 package cache_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/gomig/cache"
 	"github.com/redis/go-redis/v9"
@@ -29,3 +31,165 @@ func TestRedisQueue(t *testing.T) {
 		fmt.Println("result: ", *v)
 	}
 }
+
+func TestRedisQueueIntrospection(t *testing.T) {
+	q := cache.NewRedisQueue("test-introspection", redis.Options{Addr: "localhost:6379"})
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"a", "b", "a", "c"} {
+		if err := q.Push(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if l, err := q.Len(); err != nil {
+		t.Fatal(err)
+	} else if l != 4 {
+		t.Fatalf("want 4 items, get %d", l)
+	}
+
+	if items, err := q.Peek(2); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(items) != "[a b]" {
+		t.Fatalf("failed peek %v", items)
+	}
+
+	if removed, err := q.Remove("a"); err != nil {
+		t.Fatal(err)
+	} else if removed != 2 {
+		t.Fatalf("want 2 removed, get %d", removed)
+	}
+
+	if v, err := q.Pull(); err != nil {
+		t.Fatal(err)
+	} else if v == nil || *v != "b" {
+		t.Fatalf("failed pull after remove %v", v)
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Length != 1 || stats.Dequeued < 1 || stats.Enqueued < 4 || stats.OldestAge < 0 {
+		t.Fatalf("invalid stats %+v", stats)
+	}
+
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := q.Len(); err != nil {
+		t.Fatal(err)
+	} else if l != 0 {
+		t.Fatal("failed purge")
+	}
+}
+
+func TestRedisQueuePushUnique(t *testing.T) {
+	q := cache.NewRedisQueue("test-unique", redis.Options{Addr: "localhost:6379"})
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed, err := q.PushUnique("job-1", "send-mail", 0); err != nil {
+		t.Fatal(err)
+	} else if !pushed {
+		t.Fatal("failed push unique")
+	}
+
+	if pushed, err := q.PushUnique("job-1", "send-mail", 0); err != nil {
+		t.Fatal(err)
+	} else if pushed {
+		t.Fatal("duplicate job pushed")
+	}
+
+	if _, err := q.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed, err := q.PushUnique("job-1", "send-mail", time.Millisecond*50); err != nil {
+		t.Fatal(err)
+	} else if !pushed {
+		t.Fatal("dedup key not released after pull")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if pushed, err := q.PushUnique("job-1", "send-mail", 0); err != nil {
+		t.Fatal(err)
+	} else if !pushed {
+		t.Fatal("dedup key not released after ttl")
+	}
+
+	if l, err := q.Len(); err != nil {
+		t.Fatal(err)
+	} else if l != 2 {
+		t.Fatalf("want 2 items, get %d", l)
+	}
+
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisQueueMeta(t *testing.T) {
+	q := cache.NewRedisQueue("test-meta", redis.Options{Addr: "localhost:6379"})
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	// item pushed without meta
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	if err := client.LPush(context.TODO(), "test-meta", "plain").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Push("old"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if pushed, err := q.PushUnique("job", "new", 0); err != nil || !pushed {
+		t.Fatalf("failed push unique %v", err)
+	}
+
+	if stats, err := q.Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.OldestAge != 0 {
+		t.Fatalf("item without meta must have unknown age, get %s", stats.OldestAge)
+	}
+
+	if v, err := q.Pull(); err != nil || v == nil || *v != "plain" {
+		t.Fatalf("failed pull plain item %v %v", v, err)
+	}
+
+	if stats, err := q.Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.OldestAge < 200*time.Millisecond {
+		t.Fatalf("oldest age must belong to old item, get %s", stats.OldestAge)
+	}
+
+	if removed, err := q.Remove("old"); err != nil || removed != 1 {
+		t.Fatalf("failed remove %d %v", removed, err)
+	}
+
+	if stats, err := q.Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.Length != 1 || stats.OldestAge >= 200*time.Millisecond {
+		t.Fatalf("oldest age must belong to new item, get %+v", stats)
+	}
+
+	if removed, err := q.Remove("new"); err != nil || removed != 1 {
+		t.Fatalf("failed remove %d %v", removed, err)
+	}
+
+	if pushed, err := q.PushUnique("job", "new", 0); err != nil || !pushed {
+		t.Fatalf("dedup key must released after remove %v", err)
+	}
+
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+}
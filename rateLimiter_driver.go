@@ -4,49 +4,81 @@ import (
 	"time"
 
 	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
 )
 
+// start new window if expired and decrease retries left
+var rlHitScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	redis.call("SET", KEYS[1], tonumber(ARGV[1]) - 1, "PX", ARGV[2])
+else
+	redis.call("DECRBY", KEYS[1], 1)
+end
+return 1
+`)
+
+// consume one attempt if any left, return allowed, retries left and ttl
+var rlAttemptScript = redis.NewScript(`
+local v = redis.call("GET", KEYS[1])
+if not v then
+	if tonumber(ARGV[1]) <= 0 then
+		return {0, 0, tonumber(ARGV[2])}
+	end
+	redis.call("SET", KEYS[1], tonumber(ARGV[1]) - 1, "PX", ARGV[2])
+	return {1, tonumber(ARGV[1]) - 1, 0}
+end
+v = tonumber(v) or 0
+if v <= 0 then
+	return {0, 0, redis.call("PTTL", KEYS[1])}
+end
+redis.call("DECRBY", KEYS[1], 1)
+return {1, v - 1, 0}
+`)
+
 type rLimiter struct {
-	key   string
-	max   uint32
-	ttl   time.Duration
-	cache Cache
+	key    string
+	max    uint32
+	ttl    time.Duration
+	cache  Cache
+	client *rCache
+	rKey   string
 }
 
 func (rl rLimiter) err(pattern string, params ...any) error {
 	return utils.TaggedError([]string{"RateLimiter", rl.key}, pattern, params...)
 }
 
-func (rl rLimiter) notExistsErr() error {
-	return utils.TaggedError([]string{"RateLimiter", "NotExists", rl.key}, "%s not exists", rl.key)
-}
-
 func (rl *rLimiter) init(key string, maxAttempts uint32, ttl time.Duration, cache Cache) error {
 	rl.key = key
 	rl.max = maxAttempts
 	rl.ttl = ttl
 	rl.cache = cache
-
-	exists, err := cache.Exists(key)
-	if err != nil {
-		return rl.err(err.Error())
-	}
-
-	if !exists {
-		return cache.Put(key, maxAttempts, ttl)
-	}
-
+	rl.client, rl.rKey = redisOf(cache, key)
 	return nil
 }
 
 func (rl rLimiter) Hit() error {
-	exists, err := rl.cache.Decrement(rl.key, 1)
-	if err != nil {
+	if rl.client != nil {
+		if err := rl.client.run(
+			rlHitScript,
+			[]string{rl.rKey},
+			rl.max,
+			rl.ttl.Milliseconds(),
+		).Err(); err != nil {
+			return rl.err(err.Error())
+		}
+		return nil
+	}
+
+	defer lockKey(rl.key)()
+	if c, err := rl.cache.Cast(rl.key); err != nil {
+		return rl.err(err.Error())
+	} else if c.IsNil() {
+		if err := rl.cache.Put(rl.key, int64(rl.max)-1, rl.ttl); err != nil {
+			return rl.err(err.Error())
+		}
+	} else if _, err := rl.cache.Decrement(rl.key, 1); err != nil {
 		return rl.err(err.Error())
-	}
-
-	if !exists {
-		return rl.notExistsErr()
 	}
 	return nil
 }
@@ -58,7 +90,9 @@ func (rl rLimiter) Lock() error {
 	}
 
 	if !exists {
-		return rl.notExistsErr()
+		if err := rl.cache.Put(rl.key, 0, rl.ttl); err != nil {
+			return rl.err(err.Error())
+		}
 	}
 
 	return nil
@@ -106,7 +140,7 @@ func (rl rLimiter) TotalAttempts() (uint32, error) {
 	}
 
 	if caster.IsNil() {
-		return rl.max, nil
+		return 0, nil
 	}
 
 	v, err := caster.Int()
@@ -128,7 +162,7 @@ func (rl rLimiter) RetriesLeft() (uint32, error) {
 	}
 
 	if caster.IsNil() {
-		return 0, nil
+		return rl.max, nil
 	}
 
 	v, err := caster.Int()
@@ -145,6 +179,45 @@ func (rl rLimiter) AvailableIn() (time.Duration, error) {
 	if v, err := rl.cache.TTL(rl.key); err != nil {
 		return 0, rl.err(err.Error())
 	} else {
-		return v, nil
+		return max(0, v), nil
+	}
+}
+
+func (rl rLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	if rl.client != nil {
+		res, err := rl.client.run(
+			rlAttemptScript,
+			[]string{rl.rKey},
+			rl.max,
+			rl.ttl.Milliseconds(),
+		).Int64Slice()
+		if err != nil {
+			return false, 0, 0, rl.err(err.Error())
+		}
+		return res[0] == 1, uint32(res[1]), time.Duration(max(0, res[2])) * time.Millisecond, nil
+	}
+
+	defer lockKey(rl.key)()
+	c, err := rl.cache.Cast(rl.key)
+	if err != nil {
+		return false, 0, 0, rl.err(err.Error())
+	}
+
+	if c.IsNil() {
+		if rl.max == 0 {
+			return false, 0, rl.ttl, nil
+		} else if err := rl.cache.Put(rl.key, int64(rl.max)-1, rl.ttl); err != nil {
+			return false, 0, 0, rl.err(err.Error())
+		}
+		return true, rl.max - 1, 0, nil
+	}
+
+	if v := c.IntSafe(0); v <= 0 {
+		ttl, err := rl.AvailableIn()
+		return false, 0, ttl, err
+	} else if _, err := rl.cache.Decrement(rl.key, 1); err != nil {
+		return false, 0, 0, rl.err(err.Error())
+	} else {
+		return true, uint32(v - 1), 0, nil
 	}
 }
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// apply operation on theoretical arrival time, return operation result and new arrival time
+var gcraScript = redis.NewScript(`
+local now, interval, ttl = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local tat = math.max(tonumber(redis.call("GET", KEYS[1])) or now, now)
+local ok = 1
+if ARGV[1] == "attempt" then
+	if (ttl - (tat - now)) / interval + 1e-9 >= 1 then
+		tat = tat + interval
+	else
+		ok = 0
+	end
+elseif ARGV[1] == "hit" then
+	tat = math.min(tat + interval, now + ttl)
+elseif ARGV[1] == "lock" then
+	tat = now + ttl
+end
+if ARGV[1] ~= "peek" and ok == 1 then
+	redis.call("SET", KEYS[1], tostring(tat), "PX", math.ceil(tat - now) + 1)
+end
+return {ok, tostring(tat)}
+`)
+
+// gLimiter leaky bucket rate limiter based on generic cell rate algorithm (GCRA).
+// each attempt push theoretical arrival time (tat) forward by emission
+// interval (ttl / max attempts) and attempt allowed while tat not exceed now + ttl.
+type gLimiter struct {
+	key      string
+	max      uint32
+	ttl      float64 // milliseconds
+	interval float64 // milliseconds
+	cache    Cache
+	client   *rCache
+	rKey     string
+}
+
+func (gl gLimiter) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"RateLimiter", gl.key}, pattern, params...)
+}
+
+func (gl *gLimiter) init(key string, maxAttempts uint32, ttl time.Duration, cache Cache) error {
+	if maxAttempts == 0 || ttl < time.Millisecond {
+		return gl.err("invalid max attempts %d or ttl %s", maxAttempts, ttl)
+	}
+
+	gl.key = key
+	gl.max = maxAttempts
+	gl.ttl = float64(ttl.Milliseconds())
+	gl.interval = gl.ttl / float64(maxAttempts)
+	gl.cache = cache
+	gl.client, gl.rKey = redisOf(cache, key)
+	return nil
+}
+
+// apply apply attempt, hit, lock or peek operation, return operation result
+// and milliseconds until tat
+func (gl gLimiter) apply(op string) (bool, float64, error) {
+	now := float64(time.Now().UnixMilli())
+	if gl.client != nil {
+		res, err := gl.client.run(
+			gcraScript,
+			[]string{gl.rKey},
+			op,
+			int64(now),
+			gl.interval,
+			gl.ttl,
+		).Slice()
+		if err != nil {
+			return false, 0, gl.err(err.Error())
+		}
+
+		tat, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+		if err != nil {
+			return false, 0, gl.err(err.Error())
+		}
+		return res[0] == int64(1), math.Max(0, tat-now), nil
+	}
+
+	defer lockKey(gl.key)()
+	caster, err := gl.cache.Cast(gl.key)
+	if err != nil {
+		return false, 0, gl.err(err.Error())
+	}
+
+	tat := math.Max(caster.Float64Safe(now), now)
+	switch op {
+	case "attempt":
+		if gl.retries(tat-now) == 0 {
+			return false, tat - now, nil
+		}
+		tat = tat + gl.interval
+	case "hit":
+		tat = math.Min(tat+gl.interval, now+gl.ttl)
+	case "lock":
+		tat = now + gl.ttl
+	case "peek":
+		return true, tat - now, nil
+	}
+
+	if err := gl.cache.Put(
+		gl.key,
+		strconv.FormatFloat(tat, 'f', -1, 64),
+		time.Duration(math.Ceil(tat-now)+1)*time.Millisecond,
+	); err != nil {
+		return false, 0, gl.err(err.Error())
+	}
+	return true, tat - now, nil
+}
+
+// wait get time until next attempt allowed
+func (gl gLimiter) wait(used float64) time.Duration {
+	if gl.retries(used) > 0 {
+		return 0
+	}
+	return time.Duration((used + gl.interval - gl.ttl) * float64(time.Millisecond))
+}
+
+// retries get attempts allowed before tat reach now + ttl
+func (gl gLimiter) retries(used float64) uint32 {
+	return uint32(math.Max(0, math.Floor((gl.ttl-used)/gl.interval+1e-9)))
+}
+
+func (gl gLimiter) Hit() error {
+	_, _, err := gl.apply("hit")
+	return err
+}
+
+func (gl gLimiter) Lock() error {
+	_, _, err := gl.apply("lock")
+	return err
+}
+
+func (gl gLimiter) Reset() error {
+	if err := gl.cache.Forget(gl.key); err != nil {
+		return gl.err(err.Error())
+	}
+	return nil
+}
+
+func (gl gLimiter) Clear() error {
+	return gl.Reset()
+}
+
+func (gl gLimiter) MustLock() (bool, error) {
+	if _, used, err := gl.apply("peek"); err != nil {
+		return true, err
+	} else {
+		return gl.retries(used) == 0, nil
+	}
+}
+
+func (gl gLimiter) TotalAttempts() (uint32, error) {
+	if _, used, err := gl.apply("peek"); err != nil {
+		return gl.max, err
+	} else {
+		return gl.max - gl.retries(used), nil
+	}
+}
+
+func (gl gLimiter) RetriesLeft() (uint32, error) {
+	if _, used, err := gl.apply("peek"); err != nil {
+		return 0, err
+	} else {
+		return gl.retries(used), nil
+	}
+}
+
+func (gl gLimiter) AvailableIn() (time.Duration, error) {
+	if _, used, err := gl.apply("peek"); err != nil {
+		return 0, err
+	} else {
+		return gl.wait(used), nil
+	}
+}
+
+func (gl gLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	ok, used, err := gl.apply("attempt")
+	if err != nil {
+		return false, 0, 0, err
+	} else if ok {
+		return true, gl.retries(used), 0, nil
+	} else {
+		return false, 0, gl.wait(used), nil
+	}
+}
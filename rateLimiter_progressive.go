@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// record strike and lock for penalty of strike level if not already penalized,
+// return strike level and penalty milliseconds left
+var plStrikeScript = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl > 0 then
+	return {tonumber(redis.call("GET", KEYS[1])) or 0, ttl}
+end
+local level = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+local penalty = tonumber(ARGV[math.min(level, #ARGV - 1) + 1])
+redis.call("SET", KEYS[2], level, "PX", penalty)
+return {level, penalty}
+`)
+
+// pLimiter progressive rate limiter wrapping another limiter. when wrapped limiter
+// locked, strike recorded, wrapped limiter reset and access locked for penalty
+// of strike level. last penalty used for strikes more than penalties.
+type pLimiter struct {
+	key       string
+	limiter   RateLimiter
+	penalties []time.Duration
+	decay     time.Duration
+	cache     Cache
+	client    *rCache
+}
+
+func (pl pLimiter) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"RateLimiter", pl.key}, pattern, params...)
+}
+
+func (pl *pLimiter) init(key string, limiter RateLimiter, penalties []time.Duration, decay time.Duration, cache Cache) error {
+	if limiter == nil {
+		return pl.err("limiter is nil")
+	}
+
+	if len(penalties) == 0 || decay < time.Millisecond {
+		return pl.err("invalid penalties %v or decay %s", penalties, decay)
+	}
+
+	for _, p := range penalties {
+		if p < time.Millisecond {
+			return pl.err("invalid penalty %s", p)
+		}
+	}
+
+	pl.key = key
+	pl.limiter = limiter
+	pl.penalties = penalties
+	pl.decay = decay
+	pl.cache = cache
+	pl.client, _ = redisOf(cache, key)
+	return nil
+}
+
+func (pl pLimiter) strikesKey() string {
+	return utils.ConcatStr("-", pl.key, "strikes")
+}
+
+func (pl pLimiter) penaltyKey() string {
+	return utils.ConcatStr("-", pl.key, "penalty")
+}
+
+// penalty get active penalty time left
+func (pl pLimiter) penalty() (time.Duration, error) {
+	if ttl, err := pl.cache.TTL(pl.penaltyKey()); err != nil {
+		return 0, pl.err(err.Error())
+	} else {
+		return max(0, ttl), nil
+	}
+}
+
+// record record strike if not penalized, return active penalty time left
+func (pl pLimiter) record() (time.Duration, error) {
+	if pl.client != nil {
+		_, strikesKey := redisOf(pl.cache, pl.strikesKey())
+		_, penaltyKey := redisOf(pl.cache, pl.penaltyKey())
+		args := make([]any, 0, len(pl.penalties)+1)
+		args = append(args, pl.decay.Milliseconds())
+		for _, p := range pl.penalties {
+			args = append(args, p.Milliseconds())
+		}
+
+		res, err := pl.client.run(plStrikeScript, []string{strikesKey, penaltyKey}, args...).Int64Slice()
+		if err != nil {
+			return 0, pl.err(err.Error())
+		}
+		return time.Duration(res[1]) * time.Millisecond, nil
+	}
+
+	defer lockKey(pl.key)()
+	if ttl, err := pl.penalty(); err != nil || ttl > 0 {
+		return ttl, err
+	}
+
+	caster, err := pl.cache.Cast(pl.strikesKey())
+	if err != nil {
+		return 0, pl.err(err.Error())
+	}
+
+	level := caster.UInt32Safe(0) + 1
+	penalty := pl.penalties[min(int(level), len(pl.penalties))-1]
+	if err := pl.cache.Put(pl.strikesKey(), level, pl.decay); err != nil {
+		return 0, pl.err(err.Error())
+	}
+
+	if err := pl.cache.Put(pl.penaltyKey(), level, penalty); err != nil {
+		return 0, pl.err(err.Error())
+	}
+	return penalty, nil
+}
+
+// strike record strike and reset wrapped limiter, penalty replace wrapped
+// limiter lock and new cycle start after penalty
+func (pl pLimiter) strike() (time.Duration, error) {
+	penalty, err := pl.record()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := pl.limiter.Reset(); err != nil {
+		return 0, err
+	}
+	return penalty, nil
+}
+
+func (pl pLimiter) Hit() error {
+	if penalty, err := pl.penalty(); err != nil || penalty > 0 {
+		return err
+	}
+
+	if err := pl.limiter.Hit(); err != nil {
+		return err
+	}
+
+	if locked, err := pl.limiter.MustLock(); err != nil {
+		return err
+	} else if locked {
+		_, err := pl.strike()
+		return err
+	}
+	return nil
+}
+
+func (pl pLimiter) Lock() error {
+	_, err := pl.strike()
+	return err
+}
+
+func (pl pLimiter) Reset() error {
+	if err := pl.limiter.Reset(); err != nil {
+		return err
+	}
+
+	if err := pl.cache.Forget(pl.penaltyKey()); err != nil {
+		return pl.err(err.Error())
+	}
+	return nil
+}
+
+func (pl pLimiter) Clear() error {
+	if err := pl.limiter.Clear(); err != nil {
+		return err
+	}
+	return pl.Forgive()
+}
+
+func (pl pLimiter) MustLock() (bool, error) {
+	if penalty, err := pl.penalty(); err != nil {
+		return true, err
+	} else if penalty > 0 {
+		return true, nil
+	}
+	return pl.limiter.MustLock()
+}
+
+func (pl pLimiter) TotalAttempts() (uint32, error) {
+	total, err := pl.limiter.TotalAttempts()
+	if err != nil {
+		return total, err
+	}
+
+	// all attempts used while penalized
+	if penalty, err := pl.penalty(); err != nil {
+		return total, err
+	} else if penalty > 0 {
+		if left, err := pl.limiter.RetriesLeft(); err != nil {
+			return total, err
+		} else {
+			return total + left, nil
+		}
+	}
+	return total, nil
+}
+
+func (pl pLimiter) RetriesLeft() (uint32, error) {
+	if penalty, err := pl.penalty(); err != nil || penalty > 0 {
+		return 0, err
+	}
+	return pl.limiter.RetriesLeft()
+}
+
+func (pl pLimiter) AvailableIn() (time.Duration, error) {
+	if penalty, err := pl.penalty(); err != nil || penalty > 0 {
+		return penalty, err
+	}
+	return pl.limiter.AvailableIn()
+}
+
+func (pl pLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	if penalty, err := pl.penalty(); err != nil {
+		return false, 0, 0, err
+	} else if penalty > 0 {
+		return false, 0, penalty, nil
+	}
+
+	allowed, left, wait, err := pl.limiter.Attempt()
+	if err != nil || allowed {
+		return allowed, left, wait, err
+	}
+
+	if penalty, err := pl.strike(); err != nil {
+		return false, 0, 0, err
+	} else {
+		return false, 0, penalty, nil
+	}
+}
+
+func (pl pLimiter) PenaltyLevel() (uint32, error) {
+	if caster, err := pl.cache.Cast(pl.strikesKey()); err != nil {
+		return 0, pl.err(err.Error())
+	} else {
+		return caster.UInt32Safe(0), nil
+	}
+}
+
+func (pl pLimiter) Forgive() error {
+	if err := pl.cache.Forget(pl.strikesKey()); err != nil {
+		return pl.err(err.Error())
+	}
+
+	if err := pl.cache.Forget(pl.penaltyKey()); err != nil {
+		return pl.err(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gomig/caster"
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+var scHitScript = redis.NewScript(`
+redis.call("INCRBY", KEYS[1], ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// increment current window if estimated attempts under limit, return allowed,
+// previous and current window attempts
+var scAttemptScript = redis.NewScript(`
+local prev = tonumber(redis.call("GET", KEYS[1])) or 0
+local curr = tonumber(redis.call("GET", KEYS[2])) or 0
+if prev * tonumber(ARGV[1]) + curr >= tonumber(ARGV[2]) then
+	return {0, prev, curr}
+end
+curr = redis.call("INCRBY", KEYS[2], 1)
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+return {1, prev, curr}
+`)
+
+// scLimiter sliding window counter rate limiter. attempts counted in fixed
+// windows and estimated as weighted sum of previous and current window.
+type scLimiter struct {
+	key    string
+	max    uint32
+	window time.Duration
+	cache  Cache
+	client *rCache
+	rKey   string
+}
+
+func (sc scLimiter) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"RateLimiter", sc.key}, pattern, params...)
+}
+
+func (sc *scLimiter) init(key string, maxAttempts uint32, window time.Duration, cache Cache) error {
+	if window < time.Millisecond {
+		return sc.err("invalid window %s", window)
+	}
+
+	sc.key = key
+	sc.max = maxAttempts
+	sc.window = window
+	sc.cache = cache
+	sc.client, sc.rKey = redisOf(cache, key)
+	return nil
+}
+
+// windowOf get window index and window start time
+func (sc scLimiter) windowOf(now time.Time) (int64, time.Time) {
+	idx := now.UnixMilli() / sc.window.Milliseconds()
+	return idx, time.UnixMilli(idx * sc.window.Milliseconds())
+}
+
+func (sc scLimiter) keyOf(base string, idx int64) string {
+	return utils.ConcatStr("-", base, strconv.FormatInt(idx, 10))
+}
+
+// counts get previous and current window attempts
+func (sc scLimiter) counts(now time.Time) (float64, float64, error) {
+	idx, _ := sc.windowOf(now)
+	if sc.client != nil {
+		var vals []any
+		err := sc.client.do(true, func(ctx context.Context, client *redis.Client) (err error) {
+			vals, err = client.MGet(ctx, sc.keyOf(sc.rKey, idx-1), sc.keyOf(sc.rKey, idx)).Result()
+			return err
+		})
+		if err != nil {
+			return 0, 0, sc.err(err.Error())
+		}
+		return caster.NewCaster(vals[0]).Float64Safe(0), caster.NewCaster(vals[1]).Float64Safe(0), nil
+	}
+
+	prev, err := sc.cache.Cast(sc.keyOf(sc.key, idx-1))
+	if err != nil {
+		return 0, 0, sc.err(err.Error())
+	}
+
+	curr, err := sc.cache.Cast(sc.keyOf(sc.key, idx))
+	if err != nil {
+		return 0, 0, sc.err(err.Error())
+	}
+	return prev.Float64Safe(0), curr.Float64Safe(0), nil
+}
+
+// weight get previous window weight at now
+func (sc scLimiter) weight(now time.Time) float64 {
+	_, start := sc.windowOf(now)
+	return 1 - float64(now.Sub(start))/float64(sc.window)
+}
+
+// estimate get weighted attempts count in sliding window
+func (sc scLimiter) estimate(now time.Time) (float64, error) {
+	prev, curr, err := sc.counts(now)
+	if err != nil {
+		return 0, err
+	}
+	return prev*sc.weight(now) + curr, nil
+}
+
+// retries get attempts left for counts
+func (sc scLimiter) retries(now time.Time, prev, curr float64) uint32 {
+	v := prev*sc.weight(now) + curr
+	if v > float64(sc.max) {
+		return 0
+	}
+	return sc.max - uint32(math.Floor(v))
+}
+
+// wait get time until next attempt allowed for counts
+func (sc scLimiter) wait(now time.Time, prev, curr float64) time.Duration {
+	_, start := sc.windowOf(now)
+	max := float64(sc.max)
+	if sc.max == 0 {
+		return start.Add(sc.window).Sub(now)
+	} else if prev*sc.weight(now)+curr < max {
+		return 0
+	}
+
+	// wait until weighted previous window count drop below remaining attempts
+	var at time.Time
+	if curr < max {
+		at = start.Add(time.Duration(float64(sc.window) * (1 - (max-curr)/prev)))
+	} else {
+		at = start.Add(sc.window + time.Duration(float64(sc.window)*(1-max/curr)))
+	}
+
+	if at.Before(now) {
+		return 0
+	}
+	return at.Sub(now)
+}
+
+// increment increment window counter on non redis drivers
+func (sc scLimiter) increment(key string) error {
+	if c, err := sc.cache.Cast(key); err != nil {
+		return sc.err(err.Error())
+	} else if c.IsNil() {
+		if err := sc.cache.Put(key, 1, 2*sc.window); err != nil {
+			return sc.err(err.Error())
+		}
+	} else if _, err := sc.cache.Increment(key, 1); err != nil {
+		return sc.err(err.Error())
+	}
+	return nil
+}
+
+func (sc scLimiter) Hit() error {
+	idx, _ := sc.windowOf(time.Now())
+	if sc.client != nil {
+		if err := sc.client.run(
+			scHitScript,
+			[]string{sc.keyOf(sc.rKey, idx)},
+			1,
+			(2 * sc.window).Milliseconds(),
+		).Err(); err != nil {
+			return sc.err(err.Error())
+		}
+		return nil
+	}
+
+	key := sc.keyOf(sc.key, idx)
+	defer lockKey(key)()
+	return sc.increment(key)
+}
+
+func (sc scLimiter) Lock() error {
+	idx, _ := sc.windowOf(time.Now())
+	if err := sc.cache.Put(sc.keyOf(sc.key, idx), sc.max, 2*sc.window); err != nil {
+		return sc.err(err.Error())
+	}
+	return nil
+}
+
+func (sc scLimiter) Reset() error {
+	idx, _ := sc.windowOf(time.Now())
+	for _, i := range []int64{idx - 1, idx} {
+		if err := sc.cache.Forget(sc.keyOf(sc.key, i)); err != nil {
+			return sc.err(err.Error())
+		}
+	}
+	return nil
+}
+
+func (sc scLimiter) Clear() error {
+	return sc.Reset()
+}
+
+func (sc scLimiter) MustLock() (bool, error) {
+	if v, err := sc.estimate(time.Now()); err != nil {
+		return true, err
+	} else {
+		return v >= float64(sc.max), nil
+	}
+}
+
+func (sc scLimiter) TotalAttempts() (uint32, error) {
+	if left, err := sc.RetriesLeft(); err != nil {
+		return sc.max, err
+	} else {
+		return sc.max - left, nil
+	}
+}
+
+func (sc scLimiter) RetriesLeft() (uint32, error) {
+	now := time.Now()
+	if prev, curr, err := sc.counts(now); err != nil {
+		return 0, err
+	} else {
+		return sc.retries(now, prev, curr), nil
+	}
+}
+
+func (sc scLimiter) AvailableIn() (time.Duration, error) {
+	now := time.Now()
+	if prev, curr, err := sc.counts(now); err != nil {
+		return 0, err
+	} else {
+		return sc.wait(now, prev, curr), nil
+	}
+}
+
+func (sc scLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	now := time.Now()
+	idx, _ := sc.windowOf(now)
+	if sc.client != nil {
+		res, err := sc.client.run(
+			scAttemptScript,
+			[]string{sc.keyOf(sc.rKey, idx-1), sc.keyOf(sc.rKey, idx)},
+			sc.weight(now),
+			sc.max,
+			(2 * sc.window).Milliseconds(),
+		).Int64Slice()
+		if err != nil {
+			return false, 0, 0, sc.err(err.Error())
+		}
+
+		prev, curr := float64(res[1]), float64(res[2])
+		if res[0] == 1 {
+			return true, sc.retries(now, prev, curr), 0, nil
+		}
+		return false, 0, sc.wait(now, prev, curr), nil
+	}
+
+	key := sc.keyOf(sc.key, idx)
+	defer lockKey(key)()
+	prev, curr, err := sc.counts(now)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	if prev*sc.weight(now)+curr >= float64(sc.max) {
+		return false, 0, sc.wait(now, prev, curr), nil
+	}
+
+	if err := sc.increment(key); err != nil {
+		return false, 0, 0, err
+	}
+	return true, sc.retries(now, prev, curr+1), 0, nil
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// remove attempts out of window and add n new attempts
+var slAddScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", tonumber(ARGV[1]) - tonumber(ARGV[2]))
+for i = 1, tonumber(ARGV[3]) do
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[1] .. "-" .. ARGV[4] .. "-" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// add attempt if under limit, return allowed, attempts in window and oldest
+// attempt over limit
+var slAttemptScript = redis.NewScript(`
+local now, window, max = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count < max then
+	redis.call("ZADD", KEYS[1], now, now .. "-" .. ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, count + 1, 0}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], count - max, count - max, "WITHSCORES")
+return {0, count, tonumber(oldest[2]) or now}
+`)
+
+// slLimiter sliding window log rate limiter. attempt times kept in sorted set
+// on redis driver and as comma separated list on other drivers.
+type slLimiter struct {
+	key    string
+	max    uint32
+	window time.Duration
+	cache  Cache
+	client *rCache
+	rKey   string
+}
+
+func (sl slLimiter) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"RateLimiter", sl.key}, pattern, params...)
+}
+
+func (sl *slLimiter) init(key string, maxAttempts uint32, window time.Duration, cache Cache) error {
+	if window < time.Millisecond {
+		return sl.err("invalid window %s", window)
+	}
+
+	sl.key = key
+	sl.max = maxAttempts
+	sl.window = window
+	sl.cache = cache
+	sl.client, sl.rKey = redisOf(cache, key)
+	return nil
+}
+
+// attempts get sorted attempt times (unix milli) in window
+func (sl slLimiter) attempts(now time.Time) ([]int64, error) {
+	from := now.Add(-sl.window).UnixMilli()
+	res := make([]int64, 0)
+
+	if sl.client != nil {
+		var items []redis.Z
+		err := sl.client.do(true, func(ctx context.Context, client *redis.Client) (err error) {
+			items, err = client.ZRangeByScoreWithScores(
+				ctx,
+				sl.rKey,
+				&redis.ZRangeBy{Min: "(" + strconv.FormatInt(from, 10), Max: "+inf"},
+			).Result()
+			return err
+		})
+		if err != nil {
+			return nil, sl.err(err.Error())
+		}
+
+		for _, item := range items {
+			res = append(res, int64(item.Score))
+		}
+		return res, nil
+	}
+
+	caster, err := sl.cache.Cast(sl.key)
+	if err != nil {
+		return nil, sl.err(err.Error())
+	}
+
+	for _, v := range strings.Split(caster.StringSafe(""), ",") {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms > from {
+			res = append(res, ms)
+		}
+	}
+	return res, nil
+}
+
+// add register n attempts at now
+func (sl slLimiter) add(now time.Time, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	if sl.client != nil {
+		if err := sl.client.run(
+			slAddScript,
+			[]string{sl.rKey},
+			now.UnixMilli(),
+			sl.window.Milliseconds(),
+			n,
+			rand.Int63(),
+		).Err(); err != nil {
+			return sl.err(err.Error())
+		}
+		return nil
+	}
+
+	defer lockKey(sl.key)()
+	attempts, err := sl.attempts(now)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		attempts = append(attempts, now.UnixMilli())
+	}
+	return sl.save(attempts)
+}
+
+// save store attempts list on non redis drivers
+func (sl slLimiter) save(attempts []int64) error {
+	items := make([]string, 0, len(attempts))
+	for _, ms := range attempts {
+		items = append(items, strconv.FormatInt(ms, 10))
+	}
+
+	if err := sl.cache.Put(sl.key, strings.Join(items, ","), sl.window); err != nil {
+		return sl.err(err.Error())
+	}
+	return nil
+}
+
+func (sl slLimiter) Hit() error {
+	return sl.add(time.Now(), 1)
+}
+
+func (sl slLimiter) Lock() error {
+	now := time.Now()
+	attempts, err := sl.attempts(now)
+	if err != nil {
+		return err
+	}
+	return sl.add(now, int(sl.max)-len(attempts))
+}
+
+func (sl slLimiter) Reset() error {
+	if err := sl.cache.Forget(sl.key); err != nil {
+		return sl.err(err.Error())
+	}
+	return nil
+}
+
+func (sl slLimiter) Clear() error {
+	return sl.Reset()
+}
+
+func (sl slLimiter) MustLock() (bool, error) {
+	attempts, err := sl.attempts(time.Now())
+	if err != nil {
+		return true, err
+	}
+	return len(attempts) >= int(sl.max), nil
+}
+
+func (sl slLimiter) TotalAttempts() (uint32, error) {
+	attempts, err := sl.attempts(time.Now())
+	if err != nil {
+		return sl.max, err
+	}
+
+	if len(attempts) > int(sl.max) {
+		return sl.max, nil
+	}
+	return uint32(len(attempts)), nil
+}
+
+func (sl slLimiter) RetriesLeft() (uint32, error) {
+	if total, err := sl.TotalAttempts(); err != nil {
+		return 0, err
+	} else {
+		return sl.max - total, nil
+	}
+}
+
+func (sl slLimiter) AvailableIn() (time.Duration, error) {
+	now := time.Now()
+	attempts, err := sl.attempts(now)
+	if err != nil {
+		return 0, err
+	}
+
+	if sl.max == 0 {
+		return sl.window, nil
+	} else if len(attempts) < int(sl.max) {
+		return 0, nil
+	}
+
+	// one attempt freed when oldest attempt over limit leave window
+	oldest := time.UnixMilli(attempts[len(attempts)-int(sl.max)])
+	return oldest.Add(sl.window).Sub(now), nil
+}
+
+func (sl slLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	now := time.Now()
+	if sl.max == 0 {
+		return false, 0, sl.window, nil
+	}
+
+	if sl.client != nil {
+		res, err := sl.client.run(
+			slAttemptScript,
+			[]string{sl.rKey},
+			now.UnixMilli(),
+			sl.window.Milliseconds(),
+			sl.max,
+			rand.Int63(),
+		).Int64Slice()
+		if err != nil {
+			return false, 0, 0, sl.err(err.Error())
+		}
+
+		if res[0] == 1 {
+			return true, sl.max - uint32(res[1]), 0, nil
+		}
+		return false, 0, time.UnixMilli(res[2]).Add(sl.window).Sub(now), nil
+	}
+
+	defer lockKey(sl.key)()
+	attempts, err := sl.attempts(now)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	if len(attempts) >= int(sl.max) {
+		oldest := time.UnixMilli(attempts[len(attempts)-int(sl.max)])
+		return false, 0, oldest.Add(sl.window).Sub(now), nil
+	}
+
+	if err := sl.save(append(attempts, now.UnixMilli())); err != nil {
+		return false, 0, 0, err
+	}
+	return true, sl.max - uint32(len(attempts)) - 1, 0, nil
+}
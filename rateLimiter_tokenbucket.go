@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// refill bucket and apply operation, return operation result and tokens left
+var tbScript = redis.NewScript(`
+local now, burst, rate = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local s = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens, ts = tonumber(s[1]) or burst, tonumber(s[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local ok = 1
+if ARGV[1] == "attempt" then
+	if tokens >= 1 - 1e-9 then
+		tokens = math.max(0, tokens - 1)
+	else
+		ok = 0
+	end
+elseif ARGV[1] == "hit" then
+	tokens = math.max(0, tokens - 1)
+elseif ARGV[1] == "lock" then
+	tokens = 0
+end
+if ARGV[1] ~= "peek" and ok == 1 then
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", ARGV[2])
+	redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+end
+return {ok, tostring(tokens)}
+`)
+
+// tbLimiter token bucket rate limiter. bucket hold max tokens (burst) and
+// refilled continuously, full bucket refilled in ttl.
+type tbLimiter struct {
+	key    string
+	burst  uint32
+	rate   float64 // tokens per millisecond
+	cache  Cache
+	client *rCache
+	rKey   string
+}
+
+func (tb tbLimiter) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"RateLimiter", tb.key}, pattern, params...)
+}
+
+func (tb *tbLimiter) init(key string, burst uint32, ttl time.Duration, cache Cache) error {
+	if burst == 0 || ttl < time.Millisecond {
+		return tb.err("invalid burst %d or ttl %s", burst, ttl)
+	}
+
+	tb.key = key
+	tb.burst = burst
+	tb.rate = float64(burst) / float64(ttl.Milliseconds())
+	tb.cache = cache
+	tb.client, tb.rKey = redisOf(cache, key)
+	return nil
+}
+
+// apply refill bucket and apply attempt, hit, lock or peek operation, return
+// operation result and tokens left
+func (tb tbLimiter) apply(op string) (bool, float64, error) {
+	now := time.Now().UnixMilli()
+	if tb.client != nil {
+		res, err := tb.client.run(
+			tbScript,
+			[]string{tb.rKey},
+			op,
+			now,
+			tb.burst,
+			tb.rate,
+		).Slice()
+		if err != nil {
+			return false, 0, tb.err(err.Error())
+		}
+
+		tokens, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+		if err != nil {
+			return false, 0, tb.err(err.Error())
+		}
+		return res[0] == int64(1), tokens, nil
+	}
+
+	defer lockKey(tb.key)()
+	caster, err := tb.cache.Cast(tb.key)
+	if err != nil {
+		return false, 0, tb.err(err.Error())
+	}
+
+	// state stored as "tokens:timestamp"
+	tokens, ts := float64(tb.burst), now
+	if parts := strings.Split(caster.StringSafe(""), ":"); len(parts) == 2 {
+		if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
+			tokens = v
+		}
+		if v, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			ts = v
+		}
+	}
+
+	tokens = math.Min(float64(tb.burst), tokens+float64(max(0, now-ts))*tb.rate)
+	switch op {
+	case "attempt":
+		if tb.retries(tokens) == 0 {
+			return false, tokens, nil
+		}
+		tokens = math.Max(0, tokens-1)
+	case "hit":
+		tokens = math.Max(0, tokens-1)
+	case "lock":
+		tokens = 0
+	case "peek":
+		return true, tokens, nil
+	}
+
+	refill := time.Duration(math.Ceil((float64(tb.burst)-tokens)/tb.rate)+1) * time.Millisecond
+	if err := tb.cache.Put(
+		tb.key,
+		strconv.FormatFloat(tokens, 'f', -1, 64)+":"+strconv.FormatInt(now, 10),
+		refill,
+	); err != nil {
+		return false, 0, tb.err(err.Error())
+	}
+	return true, tokens, nil
+}
+
+// wait get time until next token
+func (tb tbLimiter) wait(tokens float64) time.Duration {
+	if tb.retries(tokens) > 0 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / tb.rate * float64(time.Millisecond))
+}
+
+// retries get whole tokens left
+func (tb tbLimiter) retries(tokens float64) uint32 {
+	return uint32(math.Floor(tokens + 1e-9))
+}
+
+func (tb tbLimiter) Hit() error {
+	_, _, err := tb.apply("hit")
+	return err
+}
+
+func (tb tbLimiter) Lock() error {
+	_, _, err := tb.apply("lock")
+	return err
+}
+
+func (tb tbLimiter) Reset() error {
+	if err := tb.cache.Forget(tb.key); err != nil {
+		return tb.err(err.Error())
+	}
+	return nil
+}
+
+func (tb tbLimiter) Clear() error {
+	return tb.Reset()
+}
+
+func (tb tbLimiter) MustLock() (bool, error) {
+	if _, tokens, err := tb.apply("peek"); err != nil {
+		return true, err
+	} else {
+		return tb.retries(tokens) == 0, nil
+	}
+}
+
+func (tb tbLimiter) TotalAttempts() (uint32, error) {
+	if _, tokens, err := tb.apply("peek"); err != nil {
+		return tb.burst, err
+	} else {
+		return tb.burst - tb.retries(tokens), nil
+	}
+}
+
+func (tb tbLimiter) RetriesLeft() (uint32, error) {
+	if _, tokens, err := tb.apply("peek"); err != nil {
+		return 0, err
+	} else {
+		return tb.retries(tokens), nil
+	}
+}
+
+func (tb tbLimiter) AvailableIn() (time.Duration, error) {
+	if _, tokens, err := tb.apply("peek"); err != nil {
+		return 0, err
+	} else {
+		return tb.wait(tokens), nil
+	}
+}
+
+func (tb tbLimiter) Attempt() (bool, uint32, time.Duration, error) {
+	ok, tokens, err := tb.apply("attempt")
+	if err != nil {
+		return false, 0, 0, err
+	} else if ok {
+		return true, tb.retries(tokens), 0, nil
+	} else {
+		return false, 0, tb.wait(tokens), nil
+	}
+}
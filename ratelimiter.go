@@ -2,6 +2,25 @@ package cache
 
 import "time"
 
+// RateLimiterAlgorithm rate limiter algorithm
+type RateLimiterAlgorithm int
+
+const (
+	// FixedWindow allow max attempts in window started by first attempt
+	FixedWindow RateLimiterAlgorithm = iota
+	// SlidingWindowLog allow max attempts in any window, keep time of every attempt
+	SlidingWindowLog
+	// SlidingWindowCounter allow max attempts in window estimated by weighted
+	// counters of previous and current fixed windows
+	SlidingWindowCounter
+	// TokenBucket bucket hold max attempts tokens refilled continuously, full
+	// bucket refilled in ttl
+	TokenBucket
+	// LeakyBucket allow one attempt every ttl / max attempts with bursts up
+	// to max attempts (GCRA)
+	LeakyBucket
+)
+
 // RateLimiter interface for rate limiter
 type RateLimiter interface {
 	// Hit decrease the allowed times
@@ -20,4 +39,18 @@ type RateLimiter interface {
 	RetriesLeft() (uint32, error)
 	// AvailableIn get time until unlock
 	AvailableIn() (time.Duration, error)
+	// Attempt check and hit rate limiter atomically, return allowed, retries
+	// left and time until next attempt allowed if not allowed
+	Attempt() (bool, uint32, time.Duration, error)
+}
+
+// ProgressiveRateLimiter rate limiter with escalating lockout. each time
+// limiter locked a strike recorded and limiter locked for penalty of strike
+// level. strikes forgotten after decay time without new strike
+type ProgressiveRateLimiter interface {
+	RateLimiter
+	// PenaltyLevel get current strikes count, 0 means no strike
+	PenaltyLevel() (uint32, error)
+	// Forgive remove strikes and active penalty
+	Forgive() error
 }
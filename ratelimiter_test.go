@@ -1,6 +1,9 @@
 package cache_test
 
 import (
+	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -110,3 +113,387 @@ func TestAvailable(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestSlidingWindowLog(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		limiter, err := cache.NewRateLimiterWith(cache.SlidingWindowLog, "test-sliding-log", 3, 300*time.Millisecond, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Reset(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := limiter.Hit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if locked, err := limiter.MustLock(); err != nil {
+			t.Fatal(err)
+		} else if !locked {
+			t.Fatalf("%s: limiter must lock", name)
+		}
+
+		if ttl, err := limiter.AvailableIn(); err != nil {
+			t.Fatal(err)
+		} else if ttl <= 0 || ttl > 300*time.Millisecond {
+			t.Fatalf("%s: invalid available in %s", name, ttl)
+		}
+
+		time.Sleep(350 * time.Millisecond)
+		if left, err := limiter.RetriesLeft(); err != nil {
+			t.Fatal(err)
+		} else if left != 3 {
+			t.Fatalf("%s: want 3 retries left, get %d", name, left)
+		}
+	}
+}
+
+func TestSlidingWindowCounter(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		limiter, err := cache.NewRateLimiterWith(cache.SlidingWindowCounter, "test-sliding-counter", 5, time.Minute, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Reset(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := limiter.Hit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if total, err := limiter.TotalAttempts(); err != nil {
+			t.Fatal(err)
+		} else if total != 2 {
+			t.Fatalf("%s: want 2 attempts, get %d", name, total)
+		}
+
+		if err := limiter.Lock(); err != nil {
+			t.Fatal(err)
+		}
+
+		if locked, err := limiter.MustLock(); err != nil {
+			t.Fatal(err)
+		} else if !locked {
+			t.Fatalf("%s: limiter must lock", name)
+		}
+
+		if ttl, err := limiter.AvailableIn(); err != nil {
+			t.Fatal(err)
+		} else if ttl <= 0 {
+			t.Fatalf("%s: invalid available in %s", name, ttl)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		limiter, err := cache.NewRateLimiterWith(cache.TokenBucket, "test-token-bucket", 4, 400*time.Millisecond, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Reset(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 4; i++ {
+			if err := limiter.Hit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if locked, err := limiter.MustLock(); err != nil {
+			t.Fatal(err)
+		} else if !locked {
+			t.Fatalf("%s: limiter must lock", name)
+		}
+
+		if wait, err := limiter.AvailableIn(); err != nil {
+			t.Fatal(err)
+		} else if wait <= 0 || wait > 100*time.Millisecond {
+			t.Fatalf("%s: want wait for one token, get %s", name, wait)
+		}
+
+		time.Sleep(210 * time.Millisecond)
+		if left, err := limiter.RetriesLeft(); err != nil {
+			t.Fatal(err)
+		} else if left != 2 {
+			t.Fatalf("%s: want 2 tokens refilled, get %d", name, left)
+		}
+	}
+}
+
+func TestLeakyBucket(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		limiter, err := cache.NewRateLimiterWith(cache.LeakyBucket, "test-leaky-bucket", 4, 400*time.Millisecond, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Reset(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := limiter.Hit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if total, err := limiter.TotalAttempts(); err != nil {
+			t.Fatal(err)
+		} else if total != 3 {
+			t.Fatalf("%s: want 3 attempts, get %d", name, total)
+		}
+
+		if err := limiter.Lock(); err != nil {
+			t.Fatal(err)
+		}
+
+		if wait, err := limiter.AvailableIn(); err != nil {
+			t.Fatal(err)
+		} else if wait <= 0 || wait > 100*time.Millisecond {
+			t.Fatalf("%s: want wait for one interval, get %s", name, wait)
+		}
+
+		time.Sleep(110 * time.Millisecond)
+		if locked, err := limiter.MustLock(); err != nil {
+			t.Fatal(err)
+		} else if locked {
+			t.Fatalf("%s: limiter must unlock after interval", name)
+		}
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	algorithms := map[string]cache.RateLimiterAlgorithm{
+		"fixed":           cache.FixedWindow,
+		"sliding-log":     cache.SlidingWindowLog,
+		"sliding-counter": cache.SlidingWindowCounter,
+		"token-bucket":    cache.TokenBucket,
+		"leaky-bucket":    cache.LeakyBucket,
+	}
+
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		for algName, alg := range algorithms {
+			limiter, err := cache.NewRateLimiterWith(alg, "test-attempt-"+algName, 3, time.Minute, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := limiter.Reset(); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 2; i >= 0; i-- {
+				allowed, left, _, err := limiter.Attempt()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !allowed || left != uint32(i) {
+					t.Fatalf("%s %s: want allowed with %d left, get %v %d", name, algName, i, allowed, left)
+				}
+			}
+
+			allowed, left, retryAfter, err := limiter.Attempt()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if allowed || left != 0 || retryAfter <= 0 {
+				t.Fatalf("%s %s: want denied, get %v %d %s", name, algName, allowed, left, retryAfter)
+			}
+		}
+	}
+}
+
+func TestAttemptConcurrency(t *testing.T) {
+	limiter, err := cache.NewRateLimiterWith(cache.SlidingWindowLog, "test-attempt-concurrency", 10, time.Minute, redisCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _, _, err := limiter.Attempt(); err == nil && ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != 10 {
+		t.Fatalf("want 10 allowed attempts, get %d", allowed.Load())
+	}
+}
+
+func TestFixedWindowExpiration(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		limiter, err := cache.NewRateLimiter("test-window", 2, 300*time.Millisecond, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Clear(); err != nil {
+			t.Fatal(err)
+		}
+
+		if left, err := limiter.RetriesLeft(); err != nil {
+			t.Fatal(err)
+		} else if left != 2 {
+			t.Fatalf("%s: want 2 retries before first hit, get %d", name, left)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := limiter.Hit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if locked, err := limiter.MustLock(); err != nil {
+			t.Fatal(err)
+		} else if !locked {
+			t.Fatalf("%s: limiter must lock", name)
+		}
+
+		time.Sleep(350 * time.Millisecond)
+		if locked, err := limiter.MustLock(); err != nil {
+			t.Fatal(err)
+		} else if locked {
+			t.Fatalf("%s: limiter must unlock after window", name)
+		}
+
+		// first hit after expiration start new window
+		if err := limiter.Hit(); err != nil {
+			t.Fatal(err)
+		}
+
+		if total, err := limiter.TotalAttempts(); err != nil {
+			t.Fatal(err)
+		} else if total != 1 {
+			t.Fatalf("%s: want 1 attempt in new window, get %d", name, total)
+		}
+
+		if ttl, err := limiter.AvailableIn(); err != nil {
+			t.Fatal(err)
+		} else if ttl <= 0 || ttl > 300*time.Millisecond {
+			t.Fatalf("%s: invalid new window ttl %s", name, ttl)
+		}
+	}
+}
+
+func TestProgressiveLockout(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		base, err := cache.NewRateLimiter("test-progressive", 2, time.Minute, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		limiter, err := cache.NewProgressiveRateLimiter(
+			"test-progressive",
+			base,
+			[]time.Duration{100 * time.Millisecond, 300 * time.Millisecond},
+			time.Second,
+			c,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Clear(); err != nil {
+			t.Fatal(err)
+		}
+
+		for cycle, penalty := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+			for i := 0; i < 2; i++ {
+				if allowed, _, _, err := limiter.Attempt(); err != nil {
+					t.Fatal(err)
+				} else if !allowed {
+					t.Fatalf("%s: attempt %d of cycle %d must allowed", name, i+1, cycle+1)
+				}
+			}
+
+			if allowed, _, wait, err := limiter.Attempt(); err != nil {
+				t.Fatal(err)
+			} else if allowed || wait <= penalty-50*time.Millisecond || wait > penalty {
+				t.Fatalf("%s: cycle %d want %s penalty, get %v %s", name, cycle+1, penalty, allowed, wait)
+			}
+
+			if level, err := limiter.PenaltyLevel(); err != nil {
+				t.Fatal(err)
+			} else if level != uint32(cycle+1) {
+				t.Fatalf("%s: want level %d, get %d", name, cycle+1, level)
+			}
+
+			if locked, err := limiter.MustLock(); err != nil {
+				t.Fatal(err)
+			} else if !locked {
+				t.Fatalf("%s: limiter must lock while penalized", name)
+			}
+
+			time.Sleep(penalty + 50*time.Millisecond)
+		}
+
+		// strikes decay
+		time.Sleep(time.Second)
+		if level, err := limiter.PenaltyLevel(); err != nil {
+			t.Fatal(err)
+		} else if level != 0 {
+			t.Fatalf("%s: strikes must decay, get level %d", name, level)
+		}
+
+		if err := limiter.Lock(); err != nil {
+			t.Fatal(err)
+		}
+
+		if wait, err := limiter.AvailableIn(); err != nil {
+			t.Fatal(err)
+		} else if wait <= 0 || wait > 100*time.Millisecond {
+			t.Fatalf("%s: lock after decay must use first penalty, get %s", name, wait)
+		}
+
+		if err := limiter.Forgive(); err != nil {
+			t.Fatal(err)
+		}
+
+		if left, err := limiter.RetriesLeft(); err != nil {
+			t.Fatal(err)
+		} else if left != 2 {
+			t.Fatalf("%s: want 2 retries after forgive, get %d", name, left)
+		}
+	}
+}
+
+func TestSlidingWindowInvalidWindow(t *testing.T) {
+	for _, algorithm := range []cache.RateLimiterAlgorithm{cache.SlidingWindowLog, cache.SlidingWindowCounter} {
+		for _, window := range []time.Duration{0, -time.Second} {
+			if _, err := cache.NewRateLimiterWith(algorithm, "test-invalid-window", 3, window, redisCache()); err == nil {
+				t.Fatalf("window %s must rejected", window)
+			}
+		}
+	}
+}
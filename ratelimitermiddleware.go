@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gomig/utils"
+)
+
+// RateLimitKeyFunc extract rate limiter identity from request, empty identity skip rate limit
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitOptions rate limiter middleware options
+type RateLimitOptions struct {
+	// Key identity extractor, client ip used if nil or identity is empty
+	Key RateLimitKeyFunc
+	// SkipPaths request paths not limited, path ends with * match as prefix
+	SkipPaths []string
+	// SkipKeys identities not limited
+	SkipKeys []string
+	// Skip custom skip function
+	Skip func(r *http.Request) bool
+	// OnLimit handler called for limited requests after headers set,
+	// plain 429 response written if nil
+	OnLimit http.Handler
+	// OnError handler called on rate limiter error, request passed to next handler if nil
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// ClientIPKey create identity extractor using client ip. X-Forwarded-For and
+// X-Real-IP headers used only when request come from trusted proxies (ip or cidr)
+func ClientIPKey(trustedProxies ...string) (RateLimitKeyFunc, error) {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p = p + "/32"
+			} else {
+				p = p + "/128"
+			}
+		}
+
+		_, cidr, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, utils.TaggedError([]string{"RateLimitMiddleware"}, "invalid trusted proxy %s", p)
+		}
+		trusted = append(trusted, cidr)
+	}
+
+	isTrusted := func(ip net.IP) bool {
+		for _, cidr := range trusted {
+			if cidr.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || !isTrusted(ip) {
+			return host
+		}
+
+		// first untrusted address from right is client
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			addr := net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if addr == nil {
+				break
+			} else if !isTrusted(addr) {
+				return addr.String()
+			}
+		}
+
+		if addr := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); addr != nil {
+			return addr.String()
+		}
+		return host
+	}, nil
+}
+
+// HeaderKey create identity extractor using request header (api key, user id, ...)
+func HeaderKey(header string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// NewRateLimitMiddleware create net/http middleware limiting requests by policy per identity.
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers set on
+// all limited responses and Retry-After set on 429 responses
+func NewRateLimitMiddleware(policy RateLimitPolicy, cache Cache, options RateLimitOptions) func(http.Handler) http.Handler {
+	// identity kind kept in limiter key, so key extractor identities not share
+	// quota with client ips
+	clientIP, _ := ClientIPKey()
+	key := func(r *http.Request) (string, string) {
+		if options.Key != nil {
+			if identity := options.Key(r); identity != "" {
+				return "key", identity
+			}
+		}
+		return "ip", clientIP(r)
+	}
+
+	skip := func(r *http.Request, identity string) bool {
+		if utils.Contains(options.SkipKeys, identity) {
+			return true
+		}
+
+		for _, p := range options.SkipPaths {
+			if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			} else if p == r.URL.Path {
+				return true
+			}
+		}
+		return options.Skip != nil && options.Skip(r)
+	}
+
+	seconds := func(v float64) string {
+		return strconv.FormatInt(int64(math.Ceil(v)), 10)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kind, identity := key(r)
+			if skip(r, identity) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			fail := func(err error) {
+				if options.OnError != nil {
+					options.OnError(w, r, err)
+				} else {
+					next.ServeHTTP(w, r)
+				}
+			}
+
+			limiter, err := NewRateLimiterWith(
+				policy.Algorithm,
+				utils.ConcatStr(":", "http-"+policy.Name, kind, identity),
+				policy.MaxAttempts,
+				policy.TTL,
+				cache,
+			)
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			allowed, left, retryAfter, err := limiter.Attempt()
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			reset, err := limiter.AvailableIn()
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.FormatUint(uint64(policy.MaxAttempts), 10))
+			w.Header().Set("X-RateLimit-Remaining", strconv.FormatUint(uint64(left), 10))
+			w.Header().Set("X-RateLimit-Reset", seconds(reset.Seconds()))
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", seconds(retryAfter.Seconds()))
+			if options.OnLimit != nil {
+				options.OnLimit.ServeHTTP(w, r)
+			} else {
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package cache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestClientIPKey(t *testing.T) {
+	key, err := cache.ClientIPKey("10.0.0.0/8", "192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.5:4321"
+	r.Header.Set("X-Forwarded-For", "8.8.8.8, 1.2.3.4, 192.168.1.1")
+	if ip := key(r); ip != "1.2.3.4" {
+		t.Fatalf("want 1.2.3.4, get %s", ip)
+	}
+
+	r.RemoteAddr = "5.5.5.5:4321"
+	if ip := key(r); ip != "5.5.5.5" {
+		t.Fatalf("untrusted proxy headers used, get %s", ip)
+	}
+
+	if _, err := cache.ClientIPKey("invalid"); err == nil {
+		t.Fatal("invalid trusted proxy accepted")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// unique policy per run, limiter state of previous runs not used
+	policy := cache.RateLimitPolicy{Name: fmt.Sprint("test-http-", time.Now().UnixNano()), MaxAttempts: 2, TTL: time.Minute}
+	handler := cache.NewRateLimitMiddleware(policy, redisCache(), cache.RateLimitOptions{
+		Key:       cache.HeaderKey("X-User"),
+		SkipPaths: []string{"/health", "/static/*"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(path string, user ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(user) == 0 {
+			r.Header.Set("X-User", "john")
+		} else if user[0] != "" {
+			r.Header.Set("X-User", user[0])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 1; i >= 0; i-- {
+		w := request("/api")
+		if w.Code != http.StatusOK {
+			t.Fatalf("want 200, get %d", w.Code)
+		}
+
+		if v := w.Header().Get("X-RateLimit-Remaining"); v != []string{"0", "1"}[i] {
+			t.Fatalf("want %d remaining, get %s", i, v)
+		}
+	}
+
+	w := request("/api")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("want 429, get %d", w.Code)
+	}
+
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("invalid rate limit headers %v", w.Header())
+	}
+
+	for _, path := range []string{"/health", "/static/app.js"} {
+		if w := request(path); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("%s: path must skipped", path)
+		}
+	}
+
+	// missing header limited by client ip
+	for i := 0; i < 2; i++ {
+		if w := request("/api", ""); w.Code != http.StatusOK {
+			t.Fatalf("want 200, get %d", w.Code)
+		}
+	}
+
+	if w := request("/api", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request without identity must limited by client ip, get %d", w.Code)
+	}
+
+	if w := request("/api", "jane"); w.Code != http.StatusOK {
+		t.Fatalf("other identity must allowed, get %d", w.Code)
+	}
+
+	// header identities not share quota with client ips
+	if w := request("/api", "192.0.2.1"); w.Code != http.StatusOK {
+		t.Fatalf("header identity equal to limited ip must allowed, get %d", w.Code)
+	}
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+)
+
+// Semaphore interface for distributed concurrency limiter. each semaphore
+// instance is one holder and hold at most one slot. acquired slot lease
+// expired after lease ttl to recover slots of crashed holders
+type Semaphore interface {
+	// TryAcquire acquire slot without waiting, return false if all slots in use.
+	// acquire held slot renew lease
+	TryAcquire() (bool, error)
+	// Acquire wait until slot acquired or context done
+	Acquire(ctx context.Context) error
+	// Release release held slot
+	Release() error
+	// Refresh renew lease of held slot, return false if slot not held or lease expired
+	Refresh() (bool, error)
+	// InUse get number of acquired slots
+	InUse() (uint32, error)
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomig/caster"
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// remove expired leases and acquire or refresh holder lease
+var semScript = redis.NewScript(`
+local now, lease, limit = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+local held = redis.call("ZSCORE", KEYS[1], ARGV[5])
+if held or (ARGV[1] == "acquire" and redis.call("ZCARD", KEYS[1]) < limit) then
+	redis.call("ZADD", KEYS[1], now + lease, ARGV[5])
+	redis.call("PEXPIRE", KEYS[1], lease)
+	return 1
+end
+return 0
+`)
+
+// sDriver semaphore. leases kept in sorted set scored by expiration on redis
+// driver and as comma separated holder:expiration list under guard file on file
+// driver (safe across processes sharing cache dir), other drivers not supported.
+type sDriver struct {
+	key    string
+	holder string
+	limit  uint32
+	lease  time.Duration
+	client *rCache
+	rKey   string
+	file   *fCache
+	fKey   string
+}
+
+func (s sDriver) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"Semaphore", s.key}, pattern, params...)
+}
+
+func (s *sDriver) init(key string, limit uint32, lease time.Duration, cache Cache) error {
+	if limit == 0 || lease < time.Millisecond {
+		return s.err("invalid limit %d or lease %s", limit, lease)
+	}
+
+	holder, err := utils.RandomStringFromCharset(16, "0123456789abcdefghijklmnopqrstuvwxyz")
+	if err != nil {
+		return s.err(err.Error())
+	}
+
+	s.key = key
+	s.holder = holder
+	s.limit = limit
+	s.lease = lease
+	s.client, s.rKey = redisOf(cache, key)
+	s.file, s.fKey = fileOf(cache, key)
+	if s.client == nil && s.file == nil {
+		return s.err("semaphore need redis or file cache driver, chained cache must not change values")
+	}
+	return nil
+}
+
+// leases get unexpired leases expiration (unix milli) by holder on file driver
+func (s sDriver) leases(now int64) (map[string]int64, error) {
+	rec, err := s.file.read(s.fKey)
+	if err != nil {
+		return nil, s.err(err.Error())
+	}
+
+	raw := ""
+	if rec != nil {
+		raw = caster.NewCaster(rec.Data).StringSafe("")
+	}
+
+	res := make(map[string]int64)
+	for _, v := range strings.Split(raw, ",") {
+		if holder, exp, ok := strings.Cut(v, ":"); ok {
+			if ms, err := strconv.ParseInt(exp, 10, 64); err == nil && ms > now {
+				res[holder] = ms
+			}
+		}
+	}
+	return res, nil
+}
+
+// save store leases on file driver
+func (s sDriver) save(leases map[string]int64) error {
+	var rec *record
+	if len(leases) > 0 {
+		last := int64(0)
+		items := make([]string, 0, len(leases))
+		for holder, ms := range leases {
+			items = append(items, holder+":"+strconv.FormatInt(ms, 10))
+			last = max(last, ms)
+		}
+		rec = &record{TTL: time.UnixMilli(last).UTC(), Data: strings.Join(items, ",")}
+	}
+
+	if err := s.file.commit(s.fKey, rec); err != nil {
+		return s.err(err.Error())
+	}
+	return nil
+}
+
+// guard lock semaphore file across processes
+func (s sDriver) guard() (func(), error) {
+	release, err := s.file.guard(s.fKey)
+	if err != nil {
+		return nil, s.err(err.Error())
+	}
+	return release, nil
+}
+
+// apply acquire or refresh holder lease
+func (s sDriver) apply(op string) (bool, error) {
+	now := time.Now().UnixMilli()
+	if s.client != nil {
+		res, err := s.client.run(
+			semScript,
+			[]string{s.rKey},
+			op,
+			now,
+			s.lease.Milliseconds(),
+			s.limit,
+			s.holder,
+		).Int()
+		if err != nil {
+			return false, s.err(err.Error())
+		}
+		return res == 1, nil
+	}
+
+	release, err := s.guard()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	leases, err := s.leases(now)
+	if err != nil {
+		return false, err
+	}
+
+	if _, held := leases[s.holder]; !held && (op != "acquire" || len(leases) >= int(s.limit)) {
+		return false, nil
+	}
+
+	leases[s.holder] = now + s.lease.Milliseconds()
+	if err := s.save(leases); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s sDriver) TryAcquire() (bool, error) {
+	return s.apply("acquire")
+}
+
+func (s sDriver) Acquire(ctx context.Context) error {
+	wait := 10 * time.Millisecond
+	for {
+		if ok, err := s.TryAcquire(); err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		wait = min(2*wait, 250*time.Millisecond)
+	}
+}
+
+func (s sDriver) Release() error {
+	if s.client != nil {
+		err := s.client.do(true, func(ctx context.Context, client *redis.Client) error {
+			return client.ZRem(ctx, s.rKey, s.holder).Err()
+		})
+		if err != nil {
+			return s.err(err.Error())
+		}
+		return nil
+	}
+
+	release, err := s.guard()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	leases, err := s.leases(time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	if _, held := leases[s.holder]; !held {
+		return nil
+	}
+
+	delete(leases, s.holder)
+	return s.save(leases)
+}
+
+func (s sDriver) Refresh() (bool, error) {
+	return s.apply("refresh")
+}
+
+func (s sDriver) InUse() (uint32, error) {
+	now := time.Now().UnixMilli()
+	if s.client != nil {
+		var count int64
+		err := s.client.do(true, func(ctx context.Context, client *redis.Client) (err error) {
+			count, err = client.ZCount(ctx, s.rKey, "("+strconv.FormatInt(now, 10), "+inf").Result()
+			return err
+		})
+		if err != nil {
+			return 0, s.err(err.Error())
+		}
+		return uint32(count), nil
+	}
+
+	leases, err := s.leases(now)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(len(leases)), nil
+}
@@ -0,0 +1,94 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+)
+
+func TestSemaphore(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-semaphore"); err != nil {
+			t.Fatal(err)
+		}
+
+		holders := make([]cache.Semaphore, 3)
+		for i := range holders {
+			s, err := cache.NewSemaphore("test-semaphore", 2, 300*time.Millisecond, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			holders[i] = s
+		}
+
+		for i, want := range []bool{true, true, false} {
+			if ok, err := holders[i].TryAcquire(); err != nil {
+				t.Fatal(err)
+			} else if ok != want {
+				t.Fatalf("%s: holder %d acquire want %v", name, i, want)
+			}
+		}
+
+		if n, err := holders[0].InUse(); err != nil {
+			t.Fatal(err)
+		} else if n != 2 {
+			t.Fatalf("%s: want 2 slots in use, get %d", name, n)
+		}
+
+		// held slot renewed
+		if ok, err := holders[0].TryAcquire(); err != nil || !ok {
+			t.Fatalf("%s: holder must reacquire held slot %v", name, err)
+		}
+
+		if ok, err := holders[2].Refresh(); err != nil || ok {
+			t.Fatalf("%s: refresh must fail for not held slot %v", name, err)
+		}
+
+		// wait for release
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			holders[1].Release()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		if err := holders[2].Acquire(ctx); err != nil {
+			t.Fatalf("%s: acquire after release failed %v", name, err)
+		}
+		cancel()
+
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		if err := holders[1].Acquire(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("%s: acquire must timeout, get %v", name, err)
+		}
+		cancel()
+
+		// crashed holders lease expired
+		time.Sleep(350 * time.Millisecond)
+		if n, err := holders[0].InUse(); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("%s: leases must expire, get %d in use", name, n)
+		}
+
+		if ok, err := holders[0].Refresh(); err != nil || ok {
+			t.Fatalf("%s: refresh must fail after lease expired %v", name, err)
+		}
+	}
+}
+
+func TestSemaphoreUnsupportedCache(t *testing.T) {
+	opaque := cache.Chain(redisCache(), func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+		if call.Op == "map_key" {
+			return nil, nil
+		}
+		return next(call)
+	})
+
+	if _, err := cache.NewSemaphore("test-semaphore-unsupported", 2, time.Minute, opaque); err == nil {
+		t.Fatal("semaphore on cache without native driver must fail")
+	}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import "time"
+
+// StreamMessage stream queue message
+type StreamMessage struct {
+	// ID stream entry id
+	ID string
+	// Value message value
+	Value string
+}
+
+// PendingMessage message delivered to consumer but not acknowledged yet
+type PendingMessage struct {
+	// ID stream entry id
+	ID string
+	// Consumer name of consumer owning message
+	Consumer string
+	// Idle time passed since last delivery
+	Idle time.Duration
+	// Deliveries total times message delivered
+	Deliveries int64
+}
+
+// StreamQueue interface for stream based queue drivers with consumer groups.
+//
+// Queue methods work on the consumer group of driver. Pull acknowledge message
+// immediately, use Read and Ack for at-least-once processing.
+type StreamQueue interface {
+	Queue
+	// Read read next n new messages for consumer without acknowledging them
+	Read(n int64) ([]StreamMessage, error)
+	// Ack acknowledge processed messages
+	Ack(ids ...string) error
+	// Pending list first n messages delivered to group but not acknowledged
+	Pending(n int64) ([]PendingMessage, error)
+	// Claim take ownership of n pending messages idle for at least minIdle
+	Claim(minIdle time.Duration, n int64) ([]StreamMessage, error)
+	// TrimLen trim stream to max length, return removed entries count
+	TrimLen(maxLen int64) (int64, error)
+	// TrimAge remove stream entries older than age, return removed entries count
+	TrimAge(age time.Duration) (int64, error)
+	// Group get stream queue for another consumer group and consumer on same stream
+	Group(group, consumer string) (StreamQueue, error)
+}
@@ -0,0 +1,570 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomig/caster"
+	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// release dedup marker of unique entry if marker still owned by entry
+const rsqReleaseLua = `
+local function release(id)
+	local marker = redis.call("HGET", KEYS[3], id)
+	if marker then
+		if redis.call("GET", marker) == id then
+			redis.call("DEL", marker)
+		end
+		redis.call("HDEL", KEYS[3], id)
+	end
+	redis.call("ZREM", KEYS[4], id)
+end
+local function older(a, b)
+	local am, as = string.match(a, "^(%d+)-?(%d*)$")
+	local bm, bs = string.match(b, "^(%d+)-?(%d*)$")
+	if tonumber(am) ~= tonumber(bm) then
+		return tonumber(am) < tonumber(bm)
+	end
+	return (tonumber(as) or 0) < (tonumber(bs) or 0)
+end
+`
+
+// unique entries kept in uniques hash (id to marker) and indexed by id time in
+// unique ids sorted set for releasing markers of trimmed entries in batches
+var rsqPushScript = redis.NewScript(`
+if KEYS[5] and redis.call("EXISTS", KEYS[5]) == 1 then
+	return false
+end
+local id = redis.call("XADD", KEYS[1], "*", "value", ARGV[1])
+redis.call("HINCRBY", KEYS[2], "enqueued", 1)
+if KEYS[5] then
+	if tonumber(ARGV[2]) > 0 then
+		redis.call("SET", KEYS[5], id, "PX", ARGV[2])
+	else
+		redis.call("SET", KEYS[5], id)
+	end
+	redis.call("HSET", KEYS[3], id, KEYS[5])
+	redis.call("ZADD", KEYS[4], string.match(id, "^(%d+)-"), id)
+end
+return id
+`)
+
+// marker of acknowledged entry released when all groups consumed entry
+var rsqAckScript = redis.NewScript(rsqReleaseLua + `
+local groups = {}
+for _, g in ipairs(redis.call("XINFO", "GROUPS", KEYS[1])) do
+	local info = {}
+	for i = 1, #g, 2 do
+		info[g[i]] = g[i + 1]
+	end
+	table.insert(groups, info)
+end
+local function consumed(id)
+	for _, g in ipairs(groups) do
+		if older(g["last-delivered-id"], id) then
+			return false
+		end
+		if #redis.call("XPENDING", KEYS[1], g["name"], id, id, 1) > 0 then
+			return false
+		end
+	end
+	return true
+end
+local n = 0
+for i = 2, #ARGV do
+	if redis.call("XACK", KEYS[1], ARGV[1], ARGV[i]) == 1 then
+		n = n + 1
+		if redis.call("HEXISTS", KEYS[3], ARGV[i]) == 1 and consumed(ARGV[i]) then
+			release(ARGV[i])
+		end
+	end
+end
+if n > 0 then
+	redis.call("HINCRBY", KEYS[2], "dequeued-" .. ARGV[1], n)
+end
+return n
+`)
+
+// remove entries equal to value from batch of entries started at ARGV[2]
+var rsqRemoveScript = redis.NewScript(rsqReleaseLua + `
+local entries = redis.call("XRANGE", KEYS[1], ARGV[2], "+", "COUNT", ARGV[3])
+local removed = 0
+for _, e in ipairs(entries) do
+	local fields = e[2]
+	for i = 1, #fields, 2 do
+		if fields[i] == "value" and fields[i + 1] == ARGV[1] then
+			removed = removed + redis.call("XDEL", KEYS[1], e[1])
+			release(e[1])
+		end
+	end
+end
+local last = ""
+if #entries == tonumber(ARGV[3]) then
+	last = entries[#entries][1]
+end
+return {removed, last}
+`)
+
+var rsqTrimScript = redis.NewScript(`
+return redis.call("XTRIM", KEYS[1], ARGV[1], ARGV[2])
+`)
+
+// release markers of batch of entries older than first stream entry
+var rsqReleaseTrimmedScript = redis.NewScript(rsqReleaseLua + `
+local first = redis.call("XRANGE", KEYS[1], "-", "+", "COUNT", 1)[1]
+if not first then
+	local ids = redis.call("ZRANGE", KEYS[4], 0, ARGV[1] - 1)
+	if #ids < tonumber(ARGV[1]) then
+		-- markers of entries pushed before unique ids index
+		local fields = redis.call("HSCAN", KEYS[3], 0, "COUNT", ARGV[1])[2]
+		for i = 1, #fields, 2 do
+			table.insert(ids, fields[i])
+		end
+	end
+	for _, id in ipairs(ids) do
+		release(id)
+	end
+	return #ids
+end
+local ms = string.match(first[1], "^(%d+)-")
+local ids = redis.call("ZRANGEBYSCORE", KEYS[4], "-inf", "(" .. ms, "LIMIT", 0, ARGV[1])
+for _, id in ipairs(ids) do
+	release(id)
+end
+if #ids < tonumber(ARGV[1]) then
+	for _, id in ipairs(redis.call("ZRANGEBYSCORE", KEYS[4], ms, ms)) do
+		if older(id, first[1]) then
+			release(id)
+		end
+	end
+end
+return #ids
+`)
+
+// entries scanned per script call by remove and trim
+const rsqBatch = 1000
+
+type rStreamQueue struct {
+	name     string
+	group    string
+	consumer string
+	client   *redis.Client
+}
+
+func (rStreamQueue) err(pattern string, params ...any) error {
+	return utils.TaggedError([]string{"RedisStreamQueue"}, pattern, params...)
+}
+
+func (rq *rStreamQueue) init(name, group, consumer string, opt redis.Options) error {
+	rq.name = name
+	rq.client = redis.NewClient(&opt)
+	return rq.join(group, consumer)
+}
+
+// join create consumer group if not exists
+func (rq *rStreamQueue) join(group, consumer string) error {
+	rq.group = group
+	rq.consumer = consumer
+	if err := rq.client.XGroupCreateMkStream(
+		context.TODO(),
+		rq.name,
+		rq.group,
+		"0",
+	).Err(); err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return rq.err(err.Error())
+	}
+	return nil
+}
+
+func (rq rStreamQueue) statsKey() string {
+	return utils.ConcatStr("-", rq.name, "stats")
+}
+
+func (rq rStreamQueue) uniquesKey() string {
+	return utils.ConcatStr("-", rq.name, "uniques")
+}
+
+func (rq rStreamQueue) uniqueIDsKey() string {
+	return utils.ConcatStr("-", rq.name, "unique", "ids")
+}
+
+func (rq rStreamQueue) markerKey(key string) string {
+	return utils.ConcatStr("-", rq.name, "unique", key)
+}
+
+func (rq rStreamQueue) keys() []string {
+	return []string{rq.name, rq.statsKey(), rq.uniquesKey(), rq.uniqueIDsKey()}
+}
+
+// trim trim stream and release dedup markers of trimmed entries in batches
+func (rq rStreamQueue) trim(strategy string, threshold any) (int64, error) {
+	n, err := rsqTrimScript.Run(context.TODO(), rq.client, rq.keys(), strategy, threshold).Int64()
+	if err != nil {
+		return 0, rq.err(err.Error())
+	}
+
+	for {
+		released, err := rsqReleaseTrimmedScript.Run(context.TODO(), rq.client, rq.keys(), rsqBatch).Int64()
+		if err != nil {
+			return n, rq.err(err.Error())
+		}
+
+		if released < rsqBatch {
+			return n, nil
+		}
+	}
+}
+
+func (rq rStreamQueue) groupInfo() (*redis.XInfoGroup, error) {
+	groups, err := rq.client.XInfoGroups(context.TODO(), rq.name).Result()
+	if err != nil {
+		return nil, rq.err(err.Error())
+	}
+
+	for _, g := range groups {
+		if g.Name == rq.group {
+			return &g, nil
+		}
+	}
+	return nil, rq.err("group %s not exists", rq.group)
+}
+
+func (rq rStreamQueue) messages(msgs []redis.XMessage) []StreamMessage {
+	res := make([]StreamMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, StreamMessage{
+			ID:    msg.ID,
+			Value: caster.NewCaster(msg.Values["value"]).StringSafe(""),
+		})
+	}
+	return res
+}
+
+func (rq rStreamQueue) Push(value any) error {
+	if err := rsqPushScript.Run(
+		context.TODO(),
+		rq.client,
+		rq.keys(),
+		value,
+	).Err(); err != nil {
+		return rq.err(err.Error())
+	}
+	return nil
+}
+
+func (rq rStreamQueue) PushUnique(key string, value any, ttl time.Duration) (bool, error) {
+	err := rsqPushScript.Run(
+		context.TODO(),
+		rq.client,
+		append(rq.keys(), rq.markerKey(key)),
+		value,
+		ttl.Milliseconds(),
+	).Err()
+
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	} else if err != nil {
+		return false, rq.err(err.Error())
+	}
+	return true, nil
+}
+
+func (rq rStreamQueue) Pull() (*string, error) {
+	msgs, err := rq.Read(1)
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+
+	if err := rq.Ack(msgs[0].ID); err != nil {
+		return nil, err
+	}
+
+	if msgs[0].Value == "" {
+		return nil, nil
+	}
+	return &msgs[0].Value, nil
+}
+
+// lag count entries not delivered to group. entries-read arithmetic (redis 7+)
+// used when available, may include entries removed after last delivered entry.
+// entries counted by paged range read on older servers
+func (rq rStreamQueue) lag(g *redis.XInfoGroup) (int64, error) {
+	if g.LastDeliveredID == "0-0" {
+		if n, err := rq.client.XLen(context.TODO(), rq.name).Result(); err != nil {
+			return 0, rq.err(err.Error())
+		} else {
+			return n, nil
+		}
+	}
+
+	if g.EntriesRead > 0 {
+		info, err := rq.client.XInfoStream(context.TODO(), rq.name).Result()
+		if err != nil {
+			return 0, rq.err(err.Error())
+		}
+		return min(max(0, info.EntriesAdded-g.EntriesRead), info.Length), nil
+	}
+
+	lag, start := int64(0), streamNextID(g.LastDeliveredID)
+	for {
+		msgs, err := rq.client.XRangeN(context.TODO(), rq.name, start, "+", 1000).Result()
+		if err != nil {
+			return 0, rq.err(err.Error())
+		}
+
+		lag += int64(len(msgs))
+		if len(msgs) < 1000 {
+			return lag, nil
+		}
+		start = streamNextID(msgs[len(msgs)-1].ID)
+	}
+}
+
+// waiting count pending and undelivered entries of group
+func (rq rStreamQueue) waiting(g *redis.XInfoGroup) (int64, error) {
+	if lag, err := rq.lag(g); err != nil {
+		return 0, err
+	} else {
+		return lag + g.Pending, nil
+	}
+}
+
+func (rq rStreamQueue) Len() (int64, error) {
+	if g, err := rq.groupInfo(); err != nil {
+		return 0, err
+	} else {
+		return rq.waiting(g)
+	}
+}
+
+// undelivered read first n entries not delivered to group yet
+func (rq rStreamQueue) undelivered(n int64) ([]StreamMessage, error) {
+	g, err := rq.groupInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := rq.client.XRangeN(
+		context.TODO(),
+		rq.name,
+		streamNextID(g.LastDeliveredID),
+		"+",
+		n,
+	).Result()
+	if err != nil {
+		return nil, rq.err(err.Error())
+	}
+	return rq.messages(msgs), nil
+}
+
+func (rq rStreamQueue) Peek(n int64) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	msgs, err := rq.undelivered(n)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, msg.Value)
+	}
+	return res, nil
+}
+
+// Remove delete entries equal to value, stream scanned in batches so entries
+// pushed while removing may not removed
+func (rq rStreamQueue) Remove(value any) (int64, error) {
+	removed, start := int64(0), "-"
+	for {
+		res, err := rsqRemoveScript.Run(context.TODO(), rq.client, rq.keys(), value, start, rsqBatch).Slice()
+		if err != nil || len(res) != 2 {
+			return removed, rq.err("failed to remove: %v", err)
+		}
+
+		removed += caster.NewCaster(res[0]).Int64Safe(0)
+		last := caster.NewCaster(res[1]).StringSafe("")
+		if last == "" {
+			return removed, nil
+		}
+		start = streamNextID(last)
+	}
+}
+
+func (rq rStreamQueue) Purge() error {
+	_, err := rq.trim("MAXLEN", 0)
+	return err
+}
+
+func (rq rStreamQueue) Stats() (QueueStats, error) {
+	g, err := rq.groupInfo()
+	if err != nil {
+		return QueueStats{}, err
+	}
+
+	length, err := rq.waiting(g)
+	if err != nil {
+		return QueueStats{}, err
+	}
+
+	counters, err := rq.client.HMGet(
+		context.TODO(),
+		rq.statsKey(),
+		"enqueued",
+		"dequeued-"+rq.group,
+	).Result()
+	if err != nil {
+		return QueueStats{}, rq.err(err.Error())
+	}
+
+	stats := QueueStats{
+		Length:   length,
+		Enqueued: caster.NewCaster(counters[0]).Int64Safe(0),
+		Dequeued: caster.NewCaster(counters[1]).Int64Safe(0),
+	}
+
+	// oldest waiting item is first pending message or first undelivered entry
+	oldest := ""
+	if g.Pending > 0 {
+		if p, err := rq.client.XPending(context.TODO(), rq.name, rq.group).Result(); err != nil {
+			return stats, rq.err(err.Error())
+		} else {
+			oldest = p.Lower
+		}
+	} else if next, err := rq.undelivered(1); err != nil {
+		return stats, err
+	} else if len(next) > 0 {
+		oldest = next[0].ID
+	}
+
+	if oldest != "" {
+		stats.OldestAge = time.Since(streamIDTime(oldest))
+	}
+	return stats, nil
+}
+
+func (rq rStreamQueue) Read(n int64) ([]StreamMessage, error) {
+	streams, err := rq.client.XReadGroup(context.TODO(), &redis.XReadGroupArgs{
+		Group:    rq.group,
+		Consumer: rq.consumer,
+		Streams:  []string{rq.name, ">"},
+		Count:    n,
+		Block:    -1,
+	}).Result()
+
+	if errors.Is(err, redis.Nil) {
+		return []StreamMessage{}, nil
+	} else if err != nil {
+		return nil, rq.err(err.Error())
+	}
+
+	res := make([]StreamMessage, 0)
+	for _, s := range streams {
+		res = append(res, rq.messages(s.Messages)...)
+	}
+	return res, nil
+}
+
+func (rq rStreamQueue) Ack(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []any{rq.group}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	if err := rsqAckScript.Run(
+		context.TODO(),
+		rq.client,
+		rq.keys(),
+		args...,
+	).Err(); err != nil {
+		return rq.err(err.Error())
+	}
+	return nil
+}
+
+func (rq rStreamQueue) Pending(n int64) ([]PendingMessage, error) {
+	pending, err := rq.client.XPendingExt(context.TODO(), &redis.XPendingExtArgs{
+		Stream: rq.name,
+		Group:  rq.group,
+		Start:  "-",
+		End:    "+",
+		Count:  n,
+	}).Result()
+	if err != nil {
+		return nil, rq.err(err.Error())
+	}
+
+	res := make([]PendingMessage, 0, len(pending))
+	for _, p := range pending {
+		res = append(res, PendingMessage{
+			ID:         p.ID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle,
+			Deliveries: p.RetryCount,
+		})
+	}
+	return res, nil
+}
+
+func (rq rStreamQueue) Claim(minIdle time.Duration, n int64) ([]StreamMessage, error) {
+	msgs, _, err := rq.client.XAutoClaim(context.TODO(), &redis.XAutoClaimArgs{
+		Stream:   rq.name,
+		Group:    rq.group,
+		Consumer: rq.consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    n,
+	}).Result()
+	if err != nil {
+		return nil, rq.err(err.Error())
+	}
+	return rq.messages(msgs), nil
+}
+
+func (rq rStreamQueue) TrimLen(maxLen int64) (int64, error) {
+	return rq.trim("MAXLEN", maxLen)
+}
+
+func (rq rStreamQueue) TrimAge(age time.Duration) (int64, error) {
+	minID := strconv.FormatInt(time.Now().Add(-age).UnixMilli(), 10) + "-0"
+	return rq.trim("MINID", minID)
+}
+
+func (rq rStreamQueue) Group(group, consumer string) (StreamQueue, error) {
+	q := &rStreamQueue{name: rq.name, client: rq.client}
+	if err := q.join(group, consumer); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// streamIDTime parse creation time of stream entry id
+func streamIDTime(id string) time.Time {
+	ms, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return time.UnixMilli(ms)
+}
+
+// streamNextID get smallest possible id after stream entry id
+func streamNextID(id string) string {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return "-"
+	}
+
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "-"
+	}
+	return parts[0] + "-" + strconv.FormatUint(seq+1, 10)
+}
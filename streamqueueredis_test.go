@@ -0,0 +1,247 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomig/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+func redisStreamQueue(t *testing.T) cache.StreamQueue {
+	q, err := cache.NewRedisStreamQueue("test-stream", "workers", "w1", redis.Options{Addr: "localhost:6379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestRedisStreamQueue(t *testing.T) {
+	q := redisStreamQueue(t)
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	// drain messages left from previous runs
+	if _, err := q.Read(1000); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := q.Pending(1000); err != nil {
+		t.Fatal(err)
+	} else {
+		for _, p := range pending {
+			if err := q.Ack(p.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, v := range []string{"john", "jack", "jane"} {
+		if err := q.Push(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if items, err := q.Peek(2); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(items) != "[john jack]" {
+		t.Fatalf("failed peek %v", items)
+	}
+
+	if v, err := q.Pull(); err != nil {
+		t.Fatal(err)
+	} else if v == nil || *v != "john" {
+		t.Fatalf("failed pull %v", v)
+	}
+
+	msgs, err := q.Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 1 || msgs[0].Value != "jack" {
+		t.Fatalf("failed read %v", msgs)
+	}
+
+	if l, err := q.Len(); err != nil {
+		t.Fatal(err)
+	} else if l != 2 {
+		t.Fatalf("want 2 waiting items, get %d", l)
+	}
+
+	pending, err := q.Pending(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 1 || pending[0].ID != msgs[0].ID || pending[0].Consumer != "w1" {
+		t.Fatalf("failed pending %v", pending)
+	}
+
+	// another consumer claim stale message
+	w2, err := q.Group("workers", "w2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := w2.Claim(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 1 || claimed[0].ID != msgs[0].ID {
+		t.Fatalf("failed claim %v", claimed)
+	}
+
+	if err := w2.Ack(claimed[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// separate group receive all stream entries
+	audit, err := q.Group(fmt.Sprintf("audit-%d", time.Now().UnixNano()), "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := audit.Len(); err != nil {
+		t.Fatal(err)
+	} else if l != 3 {
+		t.Fatalf("want 3 items for new group, get %d", l)
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Length != 1 || stats.Dequeued < 2 || stats.OldestAge < 0 {
+		t.Fatalf("invalid stats %+v", stats)
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil {
+		t.Fatal(err)
+	} else if !pushed {
+		t.Fatal("failed push unique")
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil {
+		t.Fatal(err)
+	} else if pushed {
+		t.Fatal("duplicate message pushed")
+	}
+
+	if n, err := q.TrimLen(1); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("want 3 trimmed, get %d", n)
+	}
+
+	if err := q.Purge(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil {
+		t.Fatal(err)
+	} else if !pushed {
+		t.Fatal("dedup key not released after purge")
+	}
+}
+
+func TestRedisStreamQueueLargeLen(t *testing.T) {
+	q, err := cache.NewRedisStreamQueue("test-large-stream", "workers", "w1", redis.Options{Addr: "localhost:6379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Purge()
+
+	for i := 0; i < 2500; i++ {
+		if err := q.Push(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if l, err := q.Len(); err != nil || l != 2500 {
+		t.Fatalf("want 2500 waiting items, get %d %v", l, err)
+	}
+
+	if msgs, err := q.Read(1); err != nil || len(msgs) != 1 {
+		t.Fatalf("failed read %v %v", msgs, err)
+	}
+
+	if l, err := q.Len(); err != nil || l != 2500 {
+		t.Fatalf("want 2500 waiting items after read, get %d %v", l, err)
+	}
+
+	// remove scan stream in batches
+	for _, v := range []string{"x", "x"} {
+		if err := q.Push(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed, err := q.Remove("x"); err != nil || removed != 2 {
+		t.Fatalf("want 2 removed, get %d %v", removed, err)
+	}
+
+	// markers of trimmed entries released in batches
+	for i := 0; i < 1500; i++ {
+		if pushed, err := q.PushUnique(fmt.Sprint("large-", i), i, 0); err != nil || !pushed {
+			t.Fatalf("failed push unique %v", err)
+		}
+	}
+
+	if _, err := q.TrimLen(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed, err := q.PushUnique("large-0", 0, 0); err != nil || !pushed {
+		t.Fatalf("dedup key of trimmed entry must released %v", err)
+	}
+
+	if pushed, err := q.PushUnique("large-1499", 1499, 0); err != nil || pushed {
+		t.Fatalf("dedup key of remaining entry must kept %v", err)
+	}
+}
+
+func TestRedisStreamQueueUniqueGroups(t *testing.T) {
+	q, err := cache.NewRedisStreamQueue("test-unique-stream", "workers", "w1", redis.Options{Addr: "localhost:6379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Purge()
+
+	audit, err := q.Group("audit", "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil || !pushed {
+		t.Fatalf("failed push unique %v", err)
+	}
+
+	if v, err := q.Pull(); err != nil || v == nil {
+		t.Fatalf("failed pull %v %v", v, err)
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil || pushed {
+		t.Fatalf("dedup key must kept until all groups consumed entry %v", err)
+	}
+
+	msgs, err := audit.Read(1)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("failed read %v %v", msgs, err)
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil || pushed {
+		t.Fatalf("dedup key must kept while entry pending %v", err)
+	}
+
+	if err := audit.Ack(msgs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed, err := q.PushUnique("report", "build-report", 0); err != nil || !pushed {
+		t.Fatalf("dedup key must released after all groups consumed entry %v", err)
+	}
+}
@@ -4,18 +4,94 @@ import "time"
 
 // VerificationCode interface for verification code
 type VerificationCode interface {
-	// Set set code
+	// Set set code with fresh ttl. value must contains check character if check
+	// digit enabled
 	Set(value string) error
-	// Generate generate a random numeric code with 5 character length
+	// Generate generate a random code with 5 character length
 	Generate() (string, error)
-	// GenerateN generate a random numeric code with special character length
+	// GenerateN generate a random code with special character length
 	GenerateN(count uint) (string, error)
 	// Clear clear code
 	Clear() error
-	// Get get code
+	// Get get code, returns error if code hashed
 	Get() (string, error)
 	// Exists check if code exists
 	Exists() (bool, error)
 	// TTL get ttl
 	TTL() (time.Duration, error)
+	// Verify compare input with code in constant time, code consumed on success.
+	// failed attempts counted and code invalidated after max attempts
+	Verify(input string) (bool, error)
+	// CanResendIn get time until next code generate allowed by resend cooldown and daily quota
+	CanResendIn() (time.Duration, error)
+}
+
+const (
+	// NumericCharset digits only code charset
+	NumericCharset = "0123456789"
+	// AlphanumericCharset digits and letters code charset
+	AlphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// UnambiguousCharset uppercase code charset without similar characters (0, O, 1, I)
+	UnambiguousCharset = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	// HexCharset hex code charset
+	HexCharset = "0123456789abcdef"
+)
+
+// VerificationCodeOption verification code option
+type VerificationCodeOption func(*vcDriver)
+
+// CodeMaxAttempts set max failed verify attempts before code invalidated, 0 means unlimited.
+// default is 5
+func CodeMaxAttempts(max uint32) VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.maxAttempts = max
+	}
+}
+
+// CodeHashed store salted HMAC-SHA256 of code instead of plain code, Get disabled in hashed mode
+func CodeHashed(secret []byte) VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.secret = secret
+		vc.hashed = true
+	}
+}
+
+// CodeCharset set generated code charset, default is NumericCharset. verify input
+// case ignored for single case charsets
+func CodeCharset(charset string) VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.charset = charset
+	}
+}
+
+// CodeGrouped format generated code in groups of size joined by separator (ABCD-1234).
+// separator ignored on verify
+func CodeGrouped(size uint, separator string) VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.group = size
+		vc.separator = separator
+	}
+}
+
+// CodeCheckDigit append check character (luhn mod n) to generated code. verify
+// input with invalid check character rejected without counting attempt. codes
+// passed to Set must contains valid check character
+func CodeCheckDigit() VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.check = true
+	}
+}
+
+// CodeResendCooldown set min interval between generating codes
+func CodeResendCooldown(cooldown time.Duration) VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.cooldown = cooldown
+	}
+}
+
+// CodeDailyQuota set max generated codes per day (utc), 0 means unlimited
+func CodeDailyQuota(quota uint32) VerificationCodeOption {
+	return func(vc *vcDriver) {
+		vc.quota = quota
+	}
 }
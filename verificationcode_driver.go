@@ -1,76 +1,389 @@
 package cache
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/gomig/utils"
+	"github.com/redis/go-redis/v9"
 )
 
+// count verify attempt and return code and attempts, code invalidated
+// if attempts exceed max
+var vcAttemptScript = redis.NewScript(`
+local code = redis.call("GET", KEYS[1])
+if not code or code == "" then
+	return false
+end
+local count = redis.call("INCR", KEYS[2])
+if count == 1 then
+	local ttl = redis.call("PTTL", KEYS[1])
+	if ttl > 0 then
+		redis.call("PEXPIRE", KEYS[2], ttl)
+	end
+end
+local max = tonumber(ARGV[1])
+if max > 0 and count > max then
+	redis.call("DEL", KEYS[1], KEYS[2])
+	return false
+end
+return {code, count}
+`)
+
+// delete code and attempts if code not changed
+var vcConsumeScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1], KEYS[2])
+end
+return 0
+`)
+
+// get time until cooldown and daily quota allow new code, store code with fresh
+// attempts and reserve cooldown and quota on issue operation
+var vcIssueScript = redis.NewScript(`
+local wait, quota = math.max(0, redis.call("PTTL", KEYS[1])), tonumber(ARGV[3])
+if quota > 0 and (tonumber(redis.call("GET", KEYS[2])) or 0) >= quota then
+	wait = math.max(wait, redis.call("PTTL", KEYS[2]))
+end
+if wait > 0 or ARGV[1] == "peek" then
+	return wait
+end
+redis.call("SET", KEYS[3], ARGV[5], "PX", ARGV[6])
+redis.call("DEL", KEYS[4])
+if tonumber(ARGV[2]) > 0 then
+	redis.call("SET", KEYS[1], 1, "PX", ARGV[2])
+end
+if quota > 0 and redis.call("INCR", KEYS[2]) == 1 then
+	redis.call("PEXPIRE", KEYS[2], ARGV[4])
+end
+return 0
+`)
+
 type vcDriver struct {
-	key   string
-	cache Cache
+	key         string
+	ttl         time.Duration
+	cache       Cache
+	maxAttempts uint32
+	hashed      bool
+	secret      []byte
+	charset     string
+	group       uint
+	separator   string
+	check       bool
+	cooldown    time.Duration
+	quota       uint32
+	client      *rCache
+	rKey        string
 }
 
 func (vc vcDriver) err(pattern string, params ...any) error {
 	return utils.TaggedError([]string{"VerificationCode", vc.key}, pattern, params...)
 }
 
+func (vc vcDriver) cooldownErr(wait time.Duration) error {
+	return utils.TaggedError([]string{"VerificationCode", "Cooldown", vc.key}, "resend available in %s", wait)
+}
+
 func (vc vcDriver) notExistsErr() error {
 	return utils.TaggedError([]string{"VerificationCode", "NotExists", vc.key}, "%s not exists", vc.key)
 }
 
-func (vc *vcDriver) init(key string, ttl time.Duration, cache Cache) error {
+func (vc *vcDriver) init(key string, ttl time.Duration, cache Cache, options ...VerificationCodeOption) error {
 	vc.key = key
+	vc.ttl = ttl
 	vc.cache = cache
+	vc.maxAttempts = 5
+	vc.charset = NumericCharset
+	vc.client, vc.rKey = redisOf(cache, key)
+	for _, option := range options {
+		option(vc)
+	}
 
-	exists, err := cache.Exists(key)
-	if err != nil {
-		return vc.err(err.Error())
+	if vc.hashed && len(vc.secret) == 0 {
+		return vc.err("hash secret is empty")
 	}
 
-	if !exists {
-		return cache.Put(key, "", ttl)
+	if len(vc.charset) < 2 {
+		return vc.err("invalid charset %q", vc.charset)
 	}
 
+	if vc.ttl < time.Millisecond {
+		return vc.err("invalid ttl %s", vc.ttl)
+	}
 	return nil
 }
 
-func (vc vcDriver) Set(value string) error {
-	exists, err := vc.cache.Set(vc.key, value)
+func (vc vcDriver) cooldownKey() string {
+	return utils.ConcatStr("-", vc.key, "cooldown")
+}
+
+func (vc vcDriver) quotaKey() string {
+	return utils.ConcatStr("-", vc.key, "quota")
+}
+
+// issue get time until cooldown and daily quota allow new code. issue operation
+// store encoded code and reserve cooldown and quota if allowed, reservation
+// not charged if code not stored
+func (vc vcDriver) issue(op, encoded string) (time.Duration, error) {
+	if vc.cooldown <= 0 && vc.quota == 0 {
+		if op == "peek" {
+			return 0, nil
+		}
+		return 0, vc.store(encoded)
+	}
+
+	// daily quota reset at utc midnight
+	now := time.Now().UTC()
+	reset := now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+	if vc.client != nil {
+		_, cooldownKey := redisOf(vc.cache, vc.cooldownKey())
+		_, quotaKey := redisOf(vc.cache, vc.quotaKey())
+		_, attemptsKey := redisOf(vc.cache, vc.attemptsKey())
+		wait, err := vc.client.run(
+			vcIssueScript,
+			[]string{cooldownKey, quotaKey, vc.rKey, attemptsKey},
+			op,
+			vc.cooldown.Milliseconds(),
+			vc.quota,
+			reset.Milliseconds(),
+			encoded,
+			vc.ttl.Milliseconds(),
+		).Int64()
+		if err != nil {
+			return 0, vc.err(err.Error())
+		}
+		return time.Duration(wait) * time.Millisecond, nil
+	}
+
+	defer lockKey(vc.cooldownKey())()
+	wait, err := vc.cache.TTL(vc.cooldownKey())
 	if err != nil {
-		return vc.err(err.Error())
+		return 0, vc.err(err.Error())
 	}
+	wait = max(0, wait)
 
-	if !exists {
-		return vc.notExistsErr()
+	caster, err := vc.cache.Cast(vc.quotaKey())
+	if err != nil {
+		return 0, vc.err(err.Error())
 	}
-	return nil
+
+	issued := caster.UInt32Safe(0)
+	if vc.quota > 0 && issued >= vc.quota {
+		if ttl, err := vc.cache.TTL(vc.quotaKey()); err != nil {
+			return 0, vc.err(err.Error())
+		} else {
+			wait = max(wait, ttl)
+		}
+	}
+
+	if wait > 0 || op == "peek" {
+		return wait, nil
+	}
+
+	if err := vc.store(encoded); err != nil {
+		return 0, err
+	}
+
+	if vc.cooldown > 0 {
+		if err := vc.cache.Put(vc.cooldownKey(), 1, vc.cooldown); err != nil {
+			return 0, vc.err(err.Error())
+		}
+	}
+
+	if vc.quota > 0 {
+		if issued > 0 {
+			if _, err := vc.cache.Set(vc.quotaKey(), issued+1); err != nil {
+				return 0, vc.err(err.Error())
+			}
+		} else if err := vc.cache.Put(vc.quotaKey(), 1, reset); err != nil {
+			return 0, vc.err(err.Error())
+		}
+	}
+	return 0, nil
 }
 
-func (vc vcDriver) Generate() (string, error) {
-	if val, err := utils.RandomStringFromCharset(5, "0123456789"); err != nil {
+// generate generate random code with check character if enabled, return formatted code
+func (vc vcDriver) generate(count uint) (string, error) {
+	code, err := utils.RandomStringFromCharset(count, vc.charset)
+	if err != nil {
 		return "", vc.err(err.Error())
-	} else {
-		return val, vc.Set(val)
 	}
-}
 
-func (vc vcDriver) GenerateN(count uint) (string, error) {
-	if val, err := utils.RandomStringFromCharset(count, "0123456789"); err != nil {
+	if vc.check {
+		code = code + string(vc.charset[vc.checksum(code, 2)])
+	}
+
+	encoded, err := vc.encode(code)
+	if err != nil {
 		return "", err
-	} else {
-		return val, vc.Set(val)
 	}
+
+	if wait, err := vc.issue("issue", encoded); err != nil {
+		return "", err
+	} else if wait > 0 {
+		return "", vc.cooldownErr(wait)
+	}
+	return vc.format(code), nil
 }
 
-func (vc vcDriver) Clear() error {
+// checksum calculate luhn mod n sum of code, factor 2 for generating
+// check character and 1 for validating
+func (vc vcDriver) checksum(code string, factor int) int {
+	n := len(vc.charset)
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(vc.charset, code[i])
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return (n - sum%n) % n
+}
+
+// valid check code characters and check character
+func (vc vcDriver) valid(code string) bool {
+	if !vc.check {
+		return true
+	}
+
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(vc.charset, code[i]) < 0 {
+			return false
+		}
+	}
+	return len(code) > 1 && vc.checksum(code, 1) == 0
+}
+
+// format split code to groups
+func (vc vcDriver) format(code string) string {
+	if vc.group == 0 || vc.separator == "" {
+		return code
+	}
+
+	groups := make([]string, 0, len(code)/int(vc.group)+1)
+	for i := 0; i < len(code); i += int(vc.group) {
+		groups = append(groups, code[i:min(i+int(vc.group), len(code))])
+	}
+	return strings.Join(groups, vc.separator)
+}
+
+// normalize remove separators and fold case for single case charsets
+func (vc vcDriver) normalize(code string) string {
+	if vc.separator != "" {
+		code = strings.ReplaceAll(code, vc.separator, "")
+	}
+
+	upper, lower := strings.ToUpper(vc.charset), strings.ToLower(vc.charset)
+	if upper == lower {
+		return code
+	} else if upper == vc.charset {
+		return strings.ToUpper(code)
+	} else if lower == vc.charset {
+		return strings.ToLower(code)
+	}
+	return code
+}
+
+// hash get hex encoded HMAC-SHA256 of salt and code
+func (vc vcDriver) hash(salt, code string) string {
+	mac := hmac.New(sha256.New, vc.secret)
+	mac.Write([]byte(salt))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encode get stored form of code, salt:hmac in hashed mode
+func (vc vcDriver) encode(code string) (string, error) {
+	if !vc.hashed {
+		return code, nil
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", vc.err(err.Error())
+	}
+
+	encoded := hex.EncodeToString(salt)
+	return encoded + ":" + vc.hash(encoded, code), nil
+}
+
+// match compare input with stored code in constant time
+func (vc vcDriver) match(stored, input string) bool {
+	if vc.hashed {
+		salt, mac, ok := strings.Cut(stored, ":")
+		if !ok {
+			return false
+		}
+		return hmac.Equal([]byte(mac), []byte(vc.hash(salt, input)))
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(input)) == 1
+}
+
+func (vc vcDriver) attemptsKey() string {
+	return utils.ConcatStr("-", vc.key, "attempts")
+}
+
+// forget remove code and failed attempts
+func (vc vcDriver) forget() error {
 	if err := vc.cache.Forget(vc.key); err != nil {
 		return vc.err(err.Error())
 	}
+
+	if err := vc.cache.Forget(vc.attemptsKey()); err != nil {
+		return vc.err(err.Error())
+	}
+	return nil
+}
+
+// store store encoded code with fresh ttl and no failed attempts
+func (vc vcDriver) store(encoded string) error {
+	// each code live for fresh ttl
+	if err := vc.cache.Put(vc.key, encoded, vc.ttl); err != nil {
+		return vc.err(err.Error())
+	}
+
+	// new code start with no failed attempts
+	if err := vc.cache.Forget(vc.attemptsKey()); err != nil {
+		return vc.err(err.Error())
+	}
 	return nil
 }
 
+func (vc vcDriver) Set(value string) error {
+	value = vc.normalize(value)
+	if !vc.valid(value) {
+		return vc.err("invalid check character of code")
+	}
+
+	if encoded, err := vc.encode(value); err != nil {
+		return err
+	} else {
+		return vc.store(encoded)
+	}
+}
+
+func (vc vcDriver) Generate() (string, error) {
+	return vc.generate(5)
+}
+
+func (vc vcDriver) GenerateN(count uint) (string, error) {
+	return vc.generate(count)
+}
+
+func (vc vcDriver) Clear() error {
+	return vc.forget()
+}
+
 func (vc vcDriver) Get() (string, error) {
+	if vc.hashed {
+		return "", vc.err("get not allowed for hashed code")
+	}
+
 	caster, err := vc.cache.Cast(vc.key)
 	if err != nil {
 		return "", vc.err(err.Error())
@@ -85,7 +398,7 @@ func (vc vcDriver) Get() (string, error) {
 		err = vc.err(err.Error())
 	}
 
-	return v, err
+	return vc.format(v), err
 }
 
 func (vc vcDriver) Exists() (bool, error) {
@@ -103,3 +416,84 @@ func (vc vcDriver) TTL() (time.Duration, error) {
 		return v, nil
 	}
 }
+
+func (vc vcDriver) Verify(input string) (bool, error) {
+	input = vc.normalize(input)
+	if !vc.valid(input) {
+		return false, nil
+	}
+
+	if vc.client != nil {
+		_, attemptsKey := redisOf(vc.cache, vc.attemptsKey())
+		res, err := vc.client.run(
+			vcAttemptScript,
+			[]string{vc.rKey, attemptsKey},
+			vc.maxAttempts,
+		).Slice()
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		} else if err != nil {
+			return false, vc.err(err.Error())
+		}
+
+		code, _ := res[0].(string)
+		count, _ := res[1].(int64)
+		match := vc.match(code, input)
+		if !match && (vc.maxAttempts == 0 || count < int64(vc.maxAttempts)) {
+			return false, nil
+		}
+
+		// consume matched code or invalidate code after last attempt
+		consumed, err := vc.client.run(
+			vcConsumeScript,
+			[]string{vc.rKey, attemptsKey},
+			code,
+		).Int()
+		if err != nil {
+			return false, vc.err(err.Error())
+		}
+		return match && consumed > 0, nil
+	}
+
+	defer lockKey(vc.key)()
+	caster, err := vc.cache.Cast(vc.key)
+	if err != nil {
+		return false, vc.err(err.Error())
+	}
+
+	code := caster.StringSafe("")
+	if code == "" {
+		return false, nil
+	}
+
+	attempts, err := vc.cache.Cast(vc.attemptsKey())
+	if err != nil {
+		return false, vc.err(err.Error())
+	}
+
+	count := attempts.UInt32Safe(0) + 1
+	if vc.maxAttempts > 0 && count > vc.maxAttempts {
+		return false, vc.forget()
+	}
+
+	match := vc.match(code, input)
+	if match || (vc.maxAttempts > 0 && count >= vc.maxAttempts) {
+		return match, vc.forget()
+	}
+
+	ttl, err := vc.cache.TTL(vc.key)
+	if err != nil {
+		return false, vc.err(err.Error())
+	}
+
+	if ttl > 0 {
+		if err := vc.cache.Put(vc.attemptsKey(), count, ttl); err != nil {
+			return false, vc.err(err.Error())
+		}
+	}
+	return false, nil
+}
+
+func (vc vcDriver) CanResendIn() (time.Duration, error) {
+	return vc.issue("peek", "")
+}
@@ -1,6 +1,9 @@
 package cache_test
 
 import (
+	"errors"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,3 +76,344 @@ func TestClearAndExists(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestVerify(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-verify"); err != nil {
+			t.Fatal(err)
+		}
+
+		vCode, err := cache.NewVerificationCode("test-verify", time.Minute, c, cache.CodeMaxAttempts(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := vCode.Set("12345"); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := vCode.Verify("11111"); err != nil || ok {
+			t.Fatalf("%s: wrong code must fail %v", name, err)
+		}
+
+		if ok, err := vCode.Verify("12345"); err != nil || !ok {
+			t.Fatalf("%s: valid code must verify %v", name, err)
+		}
+
+		// consumed on success
+		if ok, err := vCode.Verify("12345"); err != nil || ok {
+			t.Fatalf("%s: consumed code must fail %v", name, err)
+		}
+
+		if exists, err := vCode.Exists(); err != nil || exists {
+			t.Fatalf("%s: consumed code must removed %v", name, err)
+		}
+
+		// invalidated after max attempts
+		vCode, err = cache.NewVerificationCode("test-verify", time.Minute, c, cache.CodeMaxAttempts(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := vCode.Set("12345"); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if ok, err := vCode.Verify("00000"); err != nil || ok {
+				t.Fatalf("%s: wrong code must fail %v", name, err)
+			}
+		}
+
+		if ok, err := vCode.Verify("12345"); err != nil || ok {
+			t.Fatalf("%s: code must invalidated after max attempts %v", name, err)
+		}
+	}
+}
+
+func TestHashedCode(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-hashed"); err != nil {
+			t.Fatal(err)
+		}
+
+		vCode, err := cache.NewVerificationCode("test-hashed", time.Minute, c, cache.CodeHashed([]byte("secret")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, err := vCode.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stored, err := c.Cast("test-hashed"); err != nil {
+			t.Fatal(err)
+		} else if v := stored.StringSafe(""); v == "" || v == code {
+			t.Fatalf("%s: code must stored hashed, get %q", name, v)
+		}
+
+		if _, err := vCode.Get(); err == nil {
+			t.Fatalf("%s: get must fail in hashed mode", name)
+		}
+
+		if ok, err := vCode.Verify(code + "0"); err != nil || ok {
+			t.Fatalf("%s: wrong code must fail %v", name, err)
+		}
+
+		if ok, err := vCode.Verify(code); err != nil || !ok {
+			t.Fatalf("%s: valid code must verify %v", name, err)
+		}
+	}
+
+	if _, err := cache.NewVerificationCode("test-hashed", time.Minute, redisCache(), cache.CodeHashed(nil)); err == nil {
+		t.Fatal("empty secret must fail")
+	}
+}
+
+func TestCodeFormat(t *testing.T) {
+	vCode, err := cache.NewVerificationCode(
+		"test-format",
+		time.Minute,
+		redisCache(),
+		cache.CodeCharset(cache.UnambiguousCharset),
+		cache.CodeGrouped(4, "-"),
+		cache.CodeCheckDigit(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := vCode.GenerateN(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != 9 || code[4] != '-' || strings.ContainsAny(code, "01OI") {
+		t.Fatalf("invalid code format %s", code)
+	}
+
+	if v, err := vCode.Get(); err != nil || v != code {
+		t.Fatalf("get must return formatted code %s, get %s %v", code, v, err)
+	}
+
+	// typo rejected by check digit without counting attempt
+	typo := []byte(code)
+	if typo[0] == 'A' {
+		typo[0] = 'B'
+	} else {
+		typo[0] = 'A'
+	}
+	for i := 0; i < 10; i++ {
+		if ok, err := vCode.Verify(string(typo)); err != nil || ok {
+			t.Fatalf("typo must fail %v", err)
+		}
+	}
+
+	if ok, err := vCode.Verify(strings.ToLower(strings.ReplaceAll(code, "-", ""))); err != nil || !ok {
+		t.Fatalf("code must verify ignoring separator and case %v", err)
+	}
+
+	// set code must contains check character
+	if err := vCode.Set(string(typo)); err == nil {
+		t.Fatal("set code with invalid check character must fail")
+	}
+
+	if err := vCode.Set(code); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := vCode.Verify(code); err != nil || !ok {
+		t.Fatalf("set code must verify %v", err)
+	}
+
+	vCode, err = cache.NewVerificationCode("test-format", time.Minute, redisCache(), cache.CodeCharset(cache.HexCharset))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, err := vCode.GenerateN(8); err != nil {
+		t.Fatal(err)
+	} else if strings.Trim(code, cache.HexCharset) != "" {
+		t.Fatalf("invalid hex code %s", code)
+	}
+}
+
+func TestResendCooldownAndQuota(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		for _, k := range []string{"test-resend", "test-resend-cooldown", "test-resend-quota"} {
+			if err := c.Forget(k); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		vCode, err := cache.NewVerificationCode(
+			"test-resend",
+			time.Minute,
+			c,
+			cache.CodeResendCooldown(200*time.Millisecond),
+			cache.CodeDailyQuota(2),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if wait, err := vCode.CanResendIn(); err != nil || wait != 0 {
+			t.Fatalf("%s: first code must allowed, get %s %v", name, wait, err)
+		}
+
+		if _, err := vCode.Generate(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := vCode.Generate(); err == nil {
+			t.Fatalf("%s: resend must fail in cooldown", name)
+		}
+
+		if wait, err := vCode.CanResendIn(); err != nil || wait <= 0 || wait > 200*time.Millisecond {
+			t.Fatalf("%s: invalid cooldown wait %s %v", name, wait, err)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+		if _, err := vCode.Generate(); err != nil {
+			t.Fatalf("%s: resend after cooldown failed %v", name, err)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+		if _, err := vCode.Generate(); err == nil {
+			t.Fatalf("%s: resend must fail after daily quota", name)
+		}
+
+		if wait, err := vCode.CanResendIn(); err != nil || wait <= 200*time.Millisecond {
+			t.Fatalf("%s: quota wait must last until day end, get %s %v", name, wait, err)
+		}
+	}
+}
+
+func TestResendNotChargedOnFailure(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, base := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		for _, k := range []string{"test-resend-fail", "test-resend-fail-cooldown", "test-resend-fail-quota"} {
+			if err := base.Forget(k); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		failing := true
+		c := cache.Chain(base, func(call cache.CacheCall, next cache.CacheHandler) (any, error) {
+			if call.Op == "map_key" {
+				return nil, nil
+			}
+			if failing && call.Op == "put" && call.Key == "test-resend-fail" {
+				return nil, errors.New("store failed")
+			}
+			return next(call)
+		})
+
+		vCode, err := cache.NewVerificationCode(
+			"test-resend-fail",
+			time.Minute,
+			c,
+			cache.CodeResendCooldown(time.Minute),
+			cache.CodeDailyQuota(1),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := vCode.Generate(); err == nil {
+			t.Fatalf("%s: generate must fail", name)
+		}
+
+		if wait, err := vCode.CanResendIn(); err != nil || wait != 0 {
+			t.Fatalf("%s: failed issue must not charged, get %s %v", name, wait, err)
+		}
+
+		failing = false
+		if _, err := vCode.Generate(); err != nil {
+			t.Fatalf("%s: generate after failure failed %v", name, err)
+		}
+
+		if wait, err := vCode.CanResendIn(); err != nil || wait <= 0 {
+			t.Fatalf("%s: issued code must charged, get %s %v", name, wait, err)
+		}
+	}
+}
+
+func TestCodeFreshTTL(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		if err := c.Forget("test-fresh"); err != nil {
+			t.Fatal(err)
+		}
+
+		vCode, err := cache.NewVerificationCode("test-fresh", time.Second, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if exists, err := vCode.Exists(); err != nil || exists {
+			t.Fatalf("%s: code must not exists before generate %v", name, err)
+		}
+
+		if _, err := vCode.Generate(); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(1100 * time.Millisecond)
+		if exists, err := vCode.Exists(); err != nil || exists {
+			t.Fatalf("%s: code must expire %v", name, err)
+		}
+
+		// regenerate after expiration start new ttl
+		code, err := vCode.Generate()
+		if err != nil {
+			t.Fatalf("%s: regenerate failed %v", name, err)
+		}
+
+		if ttl, err := vCode.TTL(); err != nil || ttl <= 900*time.Millisecond || ttl > time.Second {
+			t.Fatalf("%s: invalid fresh ttl %s %v", name, ttl, err)
+		}
+
+		if ok, err := vCode.Verify(code); err != nil || !ok {
+			t.Fatalf("%s: regenerated code must verify %v", name, err)
+		}
+	}
+}
+
+func TestCodeTTL(t *testing.T) {
+	defer os.RemoveAll("./caches")
+	for name, c := range map[string]cache.Cache{"redis": redisCache(), "file": fileCache()} {
+		vCode, err := cache.NewVerificationCode("test-code-ttl", 2*time.Second, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := vCode.Set("12345"); err != nil {
+			t.Fatal(err)
+		}
+
+		if ttl, err := vCode.TTL(); err != nil || ttl <= time.Second || ttl > 2*time.Second {
+			t.Fatalf("%s: set code must use fresh ttl, get %s %v", name, ttl, err)
+		}
+
+		if _, err := vCode.Generate(); err != nil {
+			t.Fatal(err)
+		}
+
+		if ttl, err := vCode.TTL(); err != nil || ttl <= time.Second || ttl > 2*time.Second {
+			t.Fatalf("%s: generated code must use fresh ttl, get %s %v", name, ttl, err)
+		}
+
+		if err := vCode.Clear(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := cache.NewVerificationCode("test-code-ttl", 0, fileCache()); err == nil {
+		t.Fatal("invalid ttl must fail")
+	}
+}